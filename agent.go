@@ -2,15 +2,18 @@ package wise
 
 import (
 	"context"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/j0lvera/wise/executor"
-	"github.com/j0lvera/wise/executor/local"
 	"github.com/j0lvera/wise/models"
 
 	"github.com/rs/zerolog"
@@ -18,12 +21,30 @@ import (
 
 // baseAgent implements the Agent interface (unexported).
 type baseAgent struct {
-	model      models.Model
-	env        executor.Environment
-	cfg        Config
-	messages   []Message
-	step       int
-	totalUsage models.TokenUsage
+	model        models.Model
+	env          executor.Environment
+	cfg          Config
+	messages     []Message
+	transcript   []TranscriptEntry
+	step         int
+	totalUsage   models.TokenUsage
+	modelLog     *zerolog.Logger
+	execLog      *zerolog.Logger
+	price        Price
+	auditLog     *auditLog
+	resumed      bool
+	eventsMu     sync.Mutex
+	events       chan Event
+	approvalMu   sync.Mutex
+	prompts      prompts
+	convMu       sync.Mutex
+	lastStep     int
+	emptyCount   int
+	seenObs      map[[sha256.Size]byte]int
+	confirmed    bool
+	limit        int
+	finalCommand string
+	result       Result
 }
 
 // New creates an agent with required dependencies and optional config.
@@ -43,47 +64,237 @@ func New(model models.Model, env executor.Environment, cfg Config) (Agent, error
 			cfg.contextLimit = v
 		}
 	}
+	if cfg.maxObsBytes == nil {
+		n := DefaultMaxObservationBytes
+		cfg.maxObsBytes = &n
+	}
+	if cfg.maxEmpty == nil {
+		n := DefaultMaxEmptyResponses
+		cfg.maxEmpty = &n
+	}
+	if cfg.eventBuffer <= 0 {
+		cfg.eventBuffer = DefaultEventBuffer
+	}
+	if cfg.tracer == nil {
+		cfg.tracer = noopTracer
+	}
+	if cfg.completionMarker == "" {
+		cfg.completionMarker = DefaultCompletionMarker
+	}
 	if cfg.systemPrompt == "" {
 		cfg.systemPrompt = DefaultSystemPrompt
 	}
+	// Keep the built-in prompts in sync with a custom marker
+	if cfg.systemPrompt == DefaultSystemPrompt || cfg.systemPrompt == DefaultJSONSystemPrompt {
+		cfg.systemPrompt = strings.ReplaceAll(cfg.systemPrompt, DefaultCompletionMarker, cfg.completionMarker)
+	}
 	if cfg.output == nil {
 		cfg.output = io.Discard
 	}
+	if cfg.concurrency > 1 {
+		cfg.output = &lockedWriter{w: cfg.output}
+	}
+	if cfg.commandWriter == nil {
+		cfg.commandWriter = cfg.output
+	} else if cfg.concurrency > 1 {
+		cfg.commandWriter = &lockedWriter{w: cfg.commandWriter}
+	}
 	if cfg.parser == nil {
-		cfg.parser = NewBashParser()
+		if cfg.multiCommand {
+			cfg.parser = NewMultiBashParser()
+		} else {
+			cfg.parser = NewBashParser()
+		}
 	}
 	if cfg.logger == nil {
 		l := zerolog.Nop()
 		cfg.logger = &l
 	}
 
-	return &baseAgent{
+	// Derive component loggers, honoring per-component level overrides
+	base := cfg.logger
+	cfg.logger = componentLogger(base, ComponentAgent, cfg.logLevels)
+
+	a := &baseAgent{
 		model:    model,
 		env:      env,
 		cfg:      cfg,
 		messages: []Message{},
-	}, nil
+		modelLog: componentLogger(base, ComponentModel, cfg.logLevels),
+		execLog:  componentLogger(base, ComponentExecutor, cfg.logLevels),
+	}
+
+	p, err := parsePrompts(cfg)
+	if err != nil {
+		return nil, err
+	}
+	a.prompts = p
+
+	if a.cfg.obsTemplates, err = cfg.obsTemplates.withDefaults(); err != nil {
+		return nil, err
+	}
+
+	if cfg.auditLog != nil {
+		a.auditLog = &auditLog{w: cfg.auditLog}
+	}
+
+	// Resolve pricing once; unknown models are treated as free
+	var name string
+	if n, ok := model.(models.Named); ok {
+		name = n.Name()
+	}
+	price, ok := cfg.pricing[name]
+	if ok {
+		a.price = price
+	} else if cfg.maxCost > 0 {
+		cfg.logger.Warn().
+			Str("model", name).
+			Msg("no pricing for model, cost limit will not be enforced")
+	}
+
+	return a, nil
 }
 
 // Run executes the agent loop with the given task.
+// A resumed agent continues its restored conversation instead, with a
+// non-empty task appended as a follow-up message.
+//
+// The error is nil only when the task completed. Runs stopped by a step,
+// cost or time limit or by cancellation return a *TerminatingErr with the
+// reason, alongside partial output.
 func (a *baseAgent) Run(ctx context.Context, task string) (string, error) {
+	if a.resumed {
+		a.resumed = false
+		if task != "" {
+			a.addMessage(RoleUser, task)
+		}
+
+		a.cfg.logger.Info().
+			Int("step", a.step+1).
+			Int("max_steps", a.cfg.maxSteps).
+			Msg("agent loop resuming")
+
+		return a.loop(ctx, a.cfg.maxSteps)
+	}
+
+	system, user, err := a.renderPrompts(task)
+	if err != nil {
+		a.closeEvents()
+		return "", err
+	}
+
 	// Initialize conversation
+	a.convMu.Lock()
 	a.messages = []Message{}
+	a.transcript = nil
+	a.convMu.Unlock()
+	a.seenObs = nil
 	a.totalUsage = models.TokenUsage{}
-	a.addMessage(RoleSystem, a.cfg.systemPrompt)
-	a.addMessage(RoleUser, task)
+	a.step = 0
+	a.lastStep = 0
+	a.addMessage(RoleSystem, system)
+	a.addMessage(RoleUser, user)
 
 	a.cfg.logger.Info().
 		Int("max_steps", a.cfg.maxSteps).
 		Msg("agent loop starting")
 
+	return a.loop(ctx, a.cfg.maxSteps)
+}
+
+// Continue resumes the existing conversation with additional steps.
+// Useful after a run hit the step limit and more budget should be granted.
+func (a *baseAgent) Continue(ctx context.Context, additionalSteps int) (string, error) {
+	if len(a.messages) == 0 {
+		return "", ErrNoConversation
+	}
+	if additionalSteps <= 0 {
+		return "", fmt.Errorf("additional steps must be positive, got %d", additionalSteps)
+	}
+
+	limit := a.step + additionalSteps
+
+	a.cfg.logger.Info().
+		Int("step", a.step+1).
+		Int("max_steps", limit).
+		Msg("agent loop continuing")
+
+	return a.loop(ctx, limit)
+}
+
+// Chat sends a follow-up message in the existing conversation and runs it
+// with a fresh step budget, keeping the history of earlier turns. The
+// first message starts a new conversation, like Run.
+func (a *baseAgent) Chat(ctx context.Context, message string) (string, error) {
+	if len(a.messages) == 0 {
+		return a.Run(ctx, message)
+	}
+
+	a.resumed = false
+	a.addMessage(RoleUser, message)
+
+	limit := a.step + a.cfg.maxSteps
+
+	a.cfg.logger.Info().
+		Int("step", a.step+1).
+		Int("max_steps", limit).
+		Msg("agent loop continuing with follow-up")
+
+	return a.loop(ctx, limit)
+}
+
+// loop runs steps until termination or the step limit is reached, and
+// records the outcome for Result.
+func (a *baseAgent) loop(ctx context.Context, limit int) (string, error) {
+	defer a.closeEvents()
+
+	if a.cfg.runTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, a.cfg.runTimeout, ErrRunTimeout)
+		defer cancel()
+	}
+
+	a.finalCommand = ""
+	a.confirmed = false
+	output, err := a.runLoop(ctx, limit)
+	a.result = a.newResult(output, err)
+	return output, err
+}
+
+// runLoop is the main agent loop.
+func (a *baseAgent) runLoop(ctx context.Context, limit int) (_ string, err error) {
+	ctx, span := a.startSpan(ctx, "wise.run", attrMaxSteps.Int(limit))
+	defer func() { endSpan(span, err) }()
+
+	a.limit = limit
+
 	var lastResponse string
 
 	// Main loop
-	for a.step = 0; a.step < a.cfg.maxSteps; a.step++ {
+	for first := a.step; a.step < limit; a.step++ {
+		// Cancellation during the delay is picked up by Step
+		if a.cfg.stepDelay > 0 && a.step > first {
+			select {
+			case <-ctx.Done():
+			case <-time.After(a.cfg.stepDelay):
+			}
+		}
+
+		if a.cfg.maxCost > 0 && a.Cost() >= a.cfg.maxCost {
+			a.cfg.logger.Warn().
+				Float64("cost", a.Cost()).
+				Float64("max_cost", a.cfg.maxCost).
+				Msg("cost limit reached")
+			output := a.lastAssistantMessage()
+			a.notifyTerminate(ReasonCostLimit)
+			return output, &TerminatingErr{Reason: ReasonCostLimit, Output: output}
+		}
+
 		a.cfg.logger.Info().
 			Int("step", a.step+1).
 			Msg("step starting")
+		a.lastStep = a.step + 1
+		a.notifyStepStart()
 
 		response, err := a.Step(ctx)
 		if err != nil {
@@ -91,10 +302,13 @@ func (a *baseAgent) Run(ctx context.Context, task string) (string, error) {
 			var procErr *ProcessErr
 
 			if errors.As(err, &termErr) {
-				// Clean exit - task complete or limit reached
+				// Clean exit - task complete or limit reached. Count the
+				// step so a follow-up continues the numbering.
+				a.step++
 				a.cfg.logger.Info().
 					Str("reason", string(termErr.Reason)).
 					Msg("agent terminated")
+				a.notifyTerminate(termErr.Reason)
 				return termErr.Output, nil
 			}
 
@@ -104,23 +318,47 @@ func (a *baseAgent) Run(ctx context.Context, task string) (string, error) {
 					Str("type", string(procErr.Type)).
 					Str("message", procErr.Message).
 					Msg("process error, continuing")
-				a.addMessage(RoleUser, procErr.Message)
+				a.addFeedback(procErr.Message)
 				continue
 			}
 
 			// Check for execution errors from the environment
-			var execErr *local.ExecutionError
+			var execErr *executor.ExecutionError
 			if errors.As(err, &execErr) {
 				a.cfg.logger.Warn().
 					Str("type", string(execErr.Type)).
 					Str("message", execErr.Message).
 					Msg("execution error, continuing")
-				a.addMessage(RoleUser, execErr.Message)
+				a.addFeedback(execErr.Message)
 				continue
 			}
 
+			// Cancellation is a deliberate abort, not a failure
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				if !errors.Is(err, ctxErr) {
+					err = fmt.Errorf("%w: %w", ctxErr, err)
+				}
+				if errors.Is(context.Cause(ctx), ErrRunTimeout) {
+					a.cfg.logger.Warn().
+						Dur("run_timeout", a.cfg.runTimeout).
+						Msg("run timeout reached")
+					output := a.lastAssistantMessage()
+					a.notifyTerminate(ReasonRunTimeout)
+					return output, &TerminatingErr{Reason: ReasonRunTimeout, Output: output, Err: fmt.Errorf("%w: %w", ErrRunTimeout, err)}
+				}
+				if a.cfg.gracefulShutdown {
+					a.step++
+					return a.shutdown(ctx, err)
+				}
+				a.cfg.logger.Warn().Err(err).Msg("run cancelled")
+				output := a.lastAssistantMessage()
+				a.notifyTerminate(ReasonUserAbort)
+				return output, &TerminatingErr{Reason: ReasonUserAbort, Output: output, Err: err}
+			}
+
 			// Unrecoverable error
 			a.cfg.logger.Error().Err(err).Msg("unrecoverable error")
+			a.notifyTerminate(ReasonError)
 			return "", err
 		}
 		lastResponse = response
@@ -128,32 +366,74 @@ func (a *baseAgent) Run(ctx context.Context, task string) (string, error) {
 
 	// Step limit reached
 	a.cfg.logger.Warn().
-		Int("max_steps", a.cfg.maxSteps).
+		Int("max_steps", limit).
 		Msg("step limit reached")
-	return lastResponse, &TerminatingErr{Reason: ReasonStepLimit}
+	a.notifyTerminate(ReasonStepLimit)
+
+	// Steps rarely return output before completion; fall back to the
+	// model's last reply as the best account of progress
+	output := lastResponse
+	if output == "" {
+		output = a.lastAssistantMessage()
+	}
+	return output, &TerminatingErr{Reason: ReasonStepLimit, Output: output}
 }
 
 // Step performs a single iteration of the agent loop.
 func (a *baseAgent) Step(ctx context.Context) (string, error) {
+	ctx, span := a.startSpan(ctx, "wise.step", attrStep.Int(a.step+1))
+	response, err := a.runStep(ctx)
+	endSpan(span, err)
+	return response, err
+}
+
+// runStep queries the model and executes the actions it proposes.
+func (a *baseAgent) runStep(ctx context.Context) (string, error) {
 	if err := ctx.Err(); err != nil {
 		return "", fmt.Errorf("context cancelled: %w", err)
 	}
 
-	a.cfg.logger.Debug().Msg("querying model")
+	// Fit the conversation before querying, not after a failure
+	if a.cfg.memory != nil {
+		before := len(a.messages)
+		trimmed, err := a.cfg.memory.Trim(ctx, a.messages)
+		if r, ok := a.cfg.memory.(UsageReporter); ok {
+			a.addUsage(r.LastUsage())
+		}
+		if err != nil {
+			return "", fmt.Errorf("memory trim failed: %w", err)
+		}
+		a.convMu.Lock()
+		a.messages = trimmed
+		a.convMu.Unlock()
+		if dropped := before - len(trimmed); dropped > 0 {
+			a.cfg.logger.Debug().
+				Int("dropped", dropped).
+				Int("messages", len(trimmed)).
+				Msg("conversation trimmed")
+		}
+	}
+
+	a.modelLog.Debug().Msg("querying model")
 
 	// 1. Query the model
-	response, usage, err := a.model.Query(ctx, a.messages)
+	queryCtx, span := a.startSpan(ctx, "wise.model.query")
+	start := time.Now()
+	response, usage, err := a.queryWithRetry(queryCtx)
+	a.cfg.hooks.query(time.Since(start), usage, err)
+	span.SetAttributes(
+		attrPromptTokens.Int(usage.PromptTokens),
+		attrCompletionTokens.Int(usage.CompletionTokens),
+	)
+	endSpan(span, err)
 	if err != nil {
-		a.cfg.logger.Error().Err(err).Msg("query failed")
+		a.modelLog.Error().Err(err).Msg("query failed")
 		return "", fmt.Errorf("query failed: %w", err)
 	}
 
-	// Track cumulative token usage
-	a.totalUsage.PromptTokens += usage.PromptTokens
-	a.totalUsage.CompletionTokens += usage.CompletionTokens
-	a.totalUsage.TotalTokens += usage.TotalTokens
+	a.addUsage(usage)
 
-	logEvent := a.cfg.logger.Debug().
+	logEvent := a.modelLog.Debug().
 		Int("prompt_tokens", usage.PromptTokens).
 		Int("completion_tokens", usage.CompletionTokens).
 		Str("total_tokens", formatTokens(a.totalUsage.TotalTokens))
@@ -165,91 +445,420 @@ func (a *baseAgent) Step(ctx context.Context) (string, error) {
 	}
 	logEvent.Msg("got response")
 
-	a.cfg.logger.Trace().
+	a.modelLog.Trace().
 		Str("response", response).
 		Msg("full response")
 
-	// 2. Parse action from response
-	action, err := a.cfg.parser.ParseAction(response)
-	if err != nil {
+	// Repeated empty responses won't recover; stop before burning steps
+	if strings.TrimSpace(response) == "" {
+		a.emptyCount++
+		if limit := *a.cfg.maxEmpty; limit > 0 && a.emptyCount >= limit {
+			a.emptyCount = 0
+			return "", fmt.Errorf("%w: %d in a row", ErrEmptyResponses, limit)
+		}
+	} else {
+		a.emptyCount = 0
+	}
+	a.cfg.hooks.modelResponse(a.step+1, response)
+
+	// 2. Parse actions from response
+	actions, parseErr := a.parseActions(response)
+
+	// 3. Add assistant message before execution, condensed if the
+	// parser separates reasoning from commands. Malformed responses are
+	// kept too, so the feedback doesn't follow a user message directly.
+	message := response
+	if ts, ok := a.cfg.parser.(ThoughtSplitter); ok {
+		var thought string
+		thought, message = ts.SplitThought(response)
+		if thought != "" {
+			a.cfg.hooks.thought(a.step+1, thought)
+		}
+	}
+	if strings.TrimSpace(message) == "" {
+		message = emptyResponseNote
+	}
+	a.addMessage(RoleAssistant, message)
+
+	if parseErr != nil {
 		// Format error - will be added as feedback
-		a.cfg.logger.Debug().Err(err).Msg("failed to parse action")
+		a.cfg.logger.Debug().Err(parseErr).Msg("failed to parse action")
+		return "", parseErr
+	}
+
+	// 4. Execute the actions and stream output
+	if len(actions) > 1 {
+		return a.executeAll(ctx, actions)
+	}
+
+	output, err := a.execute(ctx, actions[0])
+	if err != nil {
 		return "", err
 	}
 
-	// 3. Add assistant message before execution
-	a.addMessage(RoleAssistant, response)
+	return a.handleOutput(ctx, actions[0], output)
+}
 
-	// 4. Execute the action and stream output
-	fmt.Fprintf(a.cfg.output, "$ %s\n", action.Command)
+// emptyResponseNote stands in for an empty response in the history,
+// since providers reject empty messages.
+const emptyResponseNote = "(empty response)"
+
+// parseActions extracts the actions from a response. Multiple actions
+// are only returned in multi-command mode with a MultiParser.
+func (a *baseAgent) parseActions(response string) ([]Action, error) {
+	var actions []Action
+	var err error
+	if mp, ok := a.cfg.parser.(MultiParser); ok && a.cfg.multiCommand {
+		actions, err = mp.ParseActions(response)
+	} else {
+		var action Action
+		if action, err = a.cfg.parser.ParseAction(response); err == nil {
+			actions = []Action{action}
+		}
+	}
 
-	a.cfg.logger.Info().
-		Str("command", action.Command).
+	// Parsers name the default marker; keep the feedback in sync with a
+	// custom one, as with the built-in system prompts
+	var procErr *ProcessErr
+	if errors.As(err, &procErr) && a.cfg.completionMarker != DefaultCompletionMarker {
+		procErr.Message = strings.ReplaceAll(procErr.Message, DefaultCompletionMarker, a.cfg.completionMarker)
+	}
+	return actions, err
+}
+
+// execute runs a single action, trying the custom action handler first.
+func (a *baseAgent) execute(ctx context.Context, action Action) (output Output, err error) {
+	handled := false
+	defer func() { a.audit(action, output, handled, err) }()
+
+	ctx, span := a.startSpan(ctx, "wise.command", attrCommandHash.String(commandHash(DisplayCommand(action))))
+	defer func() {
+		span.SetAttributes(attrExitCode.Int(output.ExitCode), attrTimedOut.Bool(output.TimedOut))
+		endSpan(span, err)
+	}()
+
+	// Ask the operator before running anything
+	if a.cfg.approval != nil {
+		a.approvalMu.Lock()
+		approved, err := a.cfg.approval(ctx, action)
+		a.approvalMu.Unlock()
+		if err != nil {
+			return Output{}, fmt.Errorf("approval failed: %w", err)
+		}
+		if !approved {
+			a.execLog.Info().
+				Str("command", DisplayCommand(action)).
+				Msg("command rejected by operator")
+			return Output{}, &ProcessErr{
+				Type:    ProcessErrRejected,
+				Message: "Command rejected by operator. Try a different approach.",
+			}
+		}
+	}
+
+	io.WriteString(a.cfg.commandWriter, echoCommand(DisplayCommand(action)))
+	a.notifyAction(action)
+
+	a.execLog.Info().
+		Str("command", DisplayCommand(action)).
 		Msg("executing command")
 
-	// Try custom action handler first
-	if a.cfg.actionHandler != nil {
-		output, handled, err := a.cfg.actionHandler(ctx, action)
+	if a.cfg.dryRun {
+		output = a.dryRunOutput(action)
+		a.notifyObservation(output)
+		return output, nil
+	}
+
+	// Try custom action handlers first
+	if a.cfg.ctxHandler != nil {
+		output, handled, err = a.cfg.ctxHandler(ctx, conversation{a}, action)
 		if handled {
-			if err != nil {
-				return "", err
-			}
-			return a.handleOutput(output)
+			a.notifyObservation(output)
+			return output, err
+		}
+	}
+	for _, h := range a.cfg.actionHandlers {
+		output, handled, err = h(ctx, action)
+		if handled {
+			a.notifyObservation(output)
+			return output, err
+		}
+	}
+	for _, t := range a.cfg.tools {
+		output, handled, err = t.HandleAction(ctx, action)
+		if handled {
+			a.notifyObservation(output)
+			return output, err
 		}
 	}
 
+	if action.Type == executor.ActionTypeWait {
+		output, err = wait(ctx, action)
+		if err == nil {
+			a.notifyObservation(output)
+		}
+		return output, err
+	}
+
 	// Default execution via environment
-	output, err := a.env.Execute(ctx, action)
+	action.Inputs = a.cfg.inputs
+	raw, err := a.env.Execute(ctx, action)
+	output = sanitizeOutput(raw)
+	if execErr := (*executor.ExecutionError)(nil); errors.As(err, &execErr) {
+		execErr.Message = sanitizeMessage(execErr.Message, raw, output)
+	}
+	a.notifyObservation(output)
+
+	// Some exit codes mean a broken environment the model can't fix
+	if output.ExitCode != 0 && slices.Contains(a.cfg.fatalExitCodes, output.ExitCode) {
+		a.execLog.Error().Int("exit_code", output.ExitCode).Msg("fatal exit code")
+		return output, fmt.Errorf("%w: command %q exited with code %d", ErrFatalExitCode, DisplayCommand(action), output.ExitCode)
+	}
 	if err != nil {
-		a.cfg.logger.Warn().Err(err).Msg("command execution failed")
-		return "", err
+		a.execLog.Warn().Err(err).Msg("command execution failed")
+		return output, err
+	}
+
+	return output, nil
+}
+
+// prefixLines prefixes every line of s, ending with a newline.
+func prefixLines(s, prefix string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	return prefix + strings.Join(lines, "\n"+prefix) + "\n"
+}
+
+// DisplayCommand returns the command shown for an action. Write actions
+// show their target instead of the file content.
+func DisplayCommand(action Action) string {
+	switch action.Type {
+	case executor.ActionTypeWriteFile:
+		return fmt.Sprintf("write %s (%d bytes)", action.Path, len(action.Command))
+	case executor.ActionTypeWait:
+		return "wait " + action.Command
+	}
+	return action.Command
+}
+
+// displayAction returns a copy of action safe to hand to hooks and event
+// subscribers, with a write action's content replaced by its display form.
+func displayAction(action Action) Action {
+	if action.Type == executor.ActionTypeWriteFile {
+		action.Command = DisplayCommand(action)
+	}
+	return action
+}
+
+// echoCommand renders a command for display like an interactive shell:
+// "$ " before the first line and "> " before continuation lines.
+func echoCommand(command string) string {
+	var b strings.Builder
+	for i, line := range strings.Split(command, "\n") {
+		if i == 0 {
+			b.WriteString("$ ")
+		} else {
+			b.WriteString("> ")
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// dryRunNote is the observation for commands skipped in dry-run mode.
+const dryRunNote = "(dry run: not executed)"
+
+// dryRunOutput synthesizes output for an action that isn't executed.
+// Commands mentioning the completion marker complete the run, since
+// their echo never happens.
+func (a *baseAgent) dryRunOutput(action Action) Output {
+	if strings.Contains(action.Command, a.cfg.completionMarker) {
+		return Output{Stdout: fmt.Sprintf("%s\n%s\n%s", a.cfg.completionMarker, dryRunNote, action.Command)}
 	}
+	return Output{Stdout: dryRunNote}
+}
+
+// executeAll runs actions in order, stopping at the first failure, and
+// adds a single combined observation to the conversation.
+func (a *baseAgent) executeAll(ctx context.Context, actions []Action) (string, error) {
+	if a.cfg.concurrency > 1 {
+		return a.executeConcurrent(ctx, actions)
+	}
+
+	parts := make([]string, 0, len(actions)+1)
+
+	for i, action := range actions {
+		output, err := a.execute(ctx, action)
+
+		var execErr *executor.ExecutionError
+		var procErr *ProcessErr
+		switch {
+		case errors.As(err, &execErr):
+			parts = append(parts, fmt.Sprintf("$ %s\n%s", DisplayCommand(action), execErr.Message))
+		case errors.As(err, &procErr):
+			parts = append(parts, fmt.Sprintf("$ %s\n%s", DisplayCommand(action), procErr.Message))
+		case err != nil:
+			return "", err
+		default:
+			a.reportOutput(output)
+			if final, done := a.checkCompletion(output); done {
+				return a.complete(action, final)
+			}
+			parts = append(parts, fmt.Sprintf("$ %s\n%s", DisplayCommand(action), a.observation(ctx, output)))
+		}
+
+		// Short-circuit on the first failure
+		if err != nil || output.ExitCode != 0 {
+			if skipped := len(actions) - i - 1; skipped > 0 {
+				parts = append(parts, fmt.Sprintf("[stopped after failure: %d remaining command(s) skipped]", skipped))
+			}
+			break
+		}
+	}
+
+	a.addFeedback(strings.Join(parts, "\n\n"))
 
-	return a.handleOutput(output)
+	return "", nil
+}
+
+// query sends the conversation to the model, streaming chunks to the
+// output writer when enabled and supported by the model. streamed is set
+// once any chunk has been written.
+func (a *baseAgent) query(ctx context.Context, streamed *bool) (string, models.TokenUsage, error) {
+	sm, ok := a.model.(models.StreamingModel)
+	if !a.cfg.streaming || !ok {
+		return a.model.Query(ctx, a.messages)
+	}
+
+	response, usage, err := sm.QueryStream(ctx, a.messages, func(chunk string) error {
+		*streamed = true
+		_, err := io.WriteString(a.cfg.output, chunk)
+		return err
+	})
+	if err == nil && response != "" && !strings.HasSuffix(response, "\n") {
+		fmt.Fprintln(a.cfg.output)
+	}
+	return response, usage, err
 }
 
 // handleOutput processes command output and checks for completion.
-func (a *baseAgent) handleOutput(output Output) (string, error) {
+func (a *baseAgent) handleOutput(ctx context.Context, action Action, output Output) (string, error) {
+	a.reportOutput(output)
+
+	// Check for completion signal in command output
+	if final, done := a.checkCompletion(output); done {
+		return a.complete(action, final)
+	}
+
+	// Add execution result as user message
+	a.addFeedback(a.observation(ctx, output))
+
+	return "", nil
+}
+
+// reportOutput prints command output and logs its completion.
+func (a *baseAgent) reportOutput(output Output) {
 	// Print output (skip if it's just the completion marker)
 	if !a.isTaskComplete(output) && strings.TrimSpace(output.Stdout) != "" {
 		fmt.Fprintln(a.cfg.output, output.Stdout)
 	}
+	if a.cfg.showStderr && strings.TrimSpace(output.Stderr) != "" {
+		io.WriteString(a.cfg.output, prefixLines(output.Stderr, "stderr: "))
+	}
 
-	a.cfg.logger.Debug().
+	a.execLog.Debug().
 		Int("output_length", len(output.String())).
 		Int("exit_code", output.ExitCode).
 		Msg("command completed")
-	a.cfg.logger.Trace().
+	a.execLog.Trace().
 		Str("output", output.String()).
 		Msg("full output")
+}
 
-	// Check for completion signal in command output
+// checkCompletion reports whether output ends the run, and its final
+// output. A completion signal that must be confirmed first doesn't end
+// the run; observation then returns the confirmation prompt.
+func (a *baseAgent) checkCompletion(output Output) (string, bool) {
 	if a.isTaskComplete(output) {
+		if a.needsConfirmation() {
+			return "", false
+		}
 		a.cfg.logger.Info().Msg("task complete signal in output")
-		return a.extractFinalOutput(output), &TerminatingErr{
-			Reason: ReasonComplete,
-			Output: a.extractFinalOutput(output),
+		return a.extractFinalOutput(output), true
+	}
+	if a.cfg.termCheck != nil {
+		if done, final := a.cfg.termCheck(output, a.history()); done {
+			a.cfg.logger.Info().Msg("termination check passed")
+			return final, true
 		}
 	}
+	return "", false
+}
 
-	// Add execution result as user message
+// observation returns the feedback for output that didn't end the run,
+// prefixed with the working directory if enabled.
+func (a *baseAgent) observation(ctx context.Context, output Output) string {
+	if a.isTaskComplete(output) {
+		return a.confirmationPrompt()
+	}
 	feedback := a.formatObservation(output)
-	a.addMessage(RoleUser, feedback)
+	if cwd := a.workingDir(ctx); cwd != "" {
+		feedback = fmt.Sprintf("[cwd: %s]\n%s", cwd, feedback)
+	}
+	return feedback
+}
 
-	return "", nil
+// workingDir returns the environment's working directory, or "" if not
+// enabled, unsupported or unknown.
+func (a *baseAgent) workingDir(ctx context.Context) string {
+	r, ok := a.env.(executor.WorkingDirReporter)
+	if !a.cfg.cwdInObs || !ok {
+		return ""
+	}
+	cwd, err := r.WorkingDir(ctx)
+	if err != nil {
+		a.execLog.Debug().Err(err).Msg("failed to get working directory")
+		return ""
+	}
+	return cwd
+}
+
+// complete terminates the run with the final output, remembering the
+// command that produced it.
+func (a *baseAgent) complete(action Action, final string) (string, error) {
+	a.finalCommand = DisplayCommand(action)
+	return final, &TerminatingErr{
+		Reason: ReasonComplete,
+		Output: final,
+	}
 }
 
-const completionMarker = "TASK_COMPLETE"
+// needsConfirmation reports whether a completion signal should be
+// questioned first, which happens once per run with confirmation enabled.
+func (a *baseAgent) needsConfirmation() bool {
+	if !a.cfg.confirmDone || a.confirmed {
+		return false
+	}
+	a.confirmed = true
+	a.cfg.logger.Info().Msg("task complete signal, asking for confirmation")
+	return true
+}
+
+// confirmationPrompt asks the model to check the whole task is done.
+func (a *baseAgent) confirmationPrompt() string {
+	return fmt.Sprintf("You signaled that the task is complete. Re-read the original task and check every part of it is done. "+
+		"If anything remains, continue working on it. Otherwise, output %s with the summary again.", a.cfg.completionMarker)
+}
 
 // isTaskComplete checks if the command output starts with the completion signal.
 func (a *baseAgent) isTaskComplete(output Output) bool {
 	firstLine := strings.SplitN(strings.TrimSpace(output.Stdout), "\n", 2)[0]
-	return strings.TrimSpace(firstLine) == completionMarker
+	return strings.TrimSpace(firstLine) == a.cfg.completionMarker
 }
 
 // extractFinalOutput returns everything after the completion marker.
 func (a *baseAgent) extractFinalOutput(output Output) string {
-	parts := strings.SplitN(output.Stdout, "\n", 2)
+	parts := strings.SplitN(strings.TrimSpace(output.Stdout), "\n", 2)
 	if len(parts) > 1 {
 		return strings.TrimSpace(parts[1])
 	}
@@ -258,43 +867,118 @@ func (a *baseAgent) extractFinalOutput(output Output) string {
 
 // formatObservation formats command output for the LLM.
 func (a *baseAgent) formatObservation(output Output) string {
+	t := a.cfg.obsTemplates
 	if strings.TrimSpace(output.Stdout) == "" && output.ExitCode == 0 {
-		return "(no output)"
+		return t.NoOutput
+	}
+
+	if a.cfg.dedupObs {
+		if step, ok := a.seenObservation(output); ok {
+			return fmt.Sprintf(t.Unchanged, step)
+		}
 	}
 
 	result := output.Stdout
 
 	// Truncate long output
-	const maxLen = 10000
-	if len(result) > maxLen {
-		head := result[:maxLen/2]
-		tail := result[len(result)-maxLen/2:]
-		result = head + "\n\n[... output truncated ...]\n\n" + tail
+	maxLen := *a.cfg.maxObsBytes
+	if maxLen > 0 && len(result) > maxLen {
+		result = truncateMiddle(result, maxLen, t.Truncated)
 	}
 
 	// Add exit code if non-zero
 	if output.ExitCode != 0 {
-		result = fmt.Sprintf("[exit code: %d]\n%s", output.ExitCode, result)
+		result = fmt.Sprintf(t.ExitCode, output.ExitCode) + result
 	}
 
 	return result
 }
 
+// addFeedback adds a user message reacting to the current step, noting
+// the steps left if enabled.
+func (a *baseAgent) addFeedback(content string) {
+	if a.cfg.stepBudgetHint {
+		content = fmt.Sprintf("%s\n\n[steps remaining: %d]", strings.TrimRight(content, "\n"), max(a.limit-a.step-1, 0))
+	}
+	a.addMessage(RoleUser, content)
+}
+
 // addMessage appends a message to the conversation history.
 func (a *baseAgent) addMessage(role string, content string) {
+	a.convMu.Lock()
 	a.messages = append(a.messages, Message{
 		Role:    role,
 		Content: content,
 	})
+	a.transcript = append(a.transcript, TranscriptEntry{
+		Step:    a.lastStep,
+		Role:    role,
+		Content: content,
+	})
+	a.convMu.Unlock()
 	a.cfg.logger.Debug().
 		Str("role", role).
 		Int("content_length", len(content)).
 		Msg("message added")
 }
 
-// Messages returns the current conversation history (for debugging/testing).
+// Close releases the environment's resources, such as containers, SSH
+// connections or a persistent shell, if it implements io.Closer.
+func (a *baseAgent) Close() error {
+	if c, ok := a.env.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// LastStep returns the number of the most recent step, counted across
+// the whole conversation.
+func (a *baseAgent) LastStep() int {
+	return a.lastStep
+}
+
+// addUsage adds to the cumulative token usage.
+func (a *baseAgent) addUsage(usage TokenUsage) {
+	a.totalUsage.PromptTokens += usage.PromptTokens
+	a.totalUsage.CompletionTokens += usage.CompletionTokens
+	a.totalUsage.TotalTokens += usage.TotalTokens
+}
+
+// Messages returns a copy of the current conversation history. It is
+// safe to call while a run is in progress.
 func (a *baseAgent) Messages() []Message {
-	return a.messages
+	return a.history()
+}
+
+// history returns a copy of the conversation, safe against concurrent
+// appends from action handlers and readers outside the run.
+func (a *baseAgent) history() []Message {
+	a.convMu.Lock()
+	defer a.convMu.Unlock()
+	return slices.Clone(a.messages)
+}
+
+// Usage returns the cumulative token usage of the current run.
+// Models that don't report usage yield zero values.
+func (a *baseAgent) Usage() TokenUsage {
+	return a.totalUsage
+}
+
+// Cost returns the estimated dollar cost of the current run.
+func (a *baseAgent) Cost() float64 {
+	return a.price.Cost(a.totalUsage)
+}
+
+// lastAssistantMessage returns the most recent model response, if any.
+func (a *baseAgent) lastAssistantMessage() string {
+	a.convMu.Lock()
+	defer a.convMu.Unlock()
+	for i := len(a.messages) - 1; i >= 0; i-- {
+		if a.messages[i].Role == RoleAssistant {
+			return a.messages[i].Content
+		}
+	}
+	return ""
 }
 
 // formatTokens formats a token count for human readability.
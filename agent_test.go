@@ -0,0 +1,220 @@
+package wise
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor/echo"
+	"github.com/j0lvera/wise/models"
+)
+
+// scriptedModel returns its responses in order, then fails.
+type scriptedModel struct {
+	mu        sync.Mutex
+	responses []string
+}
+
+func (m *scriptedModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.responses) == 0 {
+		return "", TokenUsage{}, fmt.Errorf("no scripted response left")
+	}
+	response := m.responses[0]
+	m.responses = m.responses[1:]
+	return response, TokenUsage{}, nil
+}
+
+var _ models.Model = (*scriptedModel)(nil)
+
+// roles returns the role of each message.
+func roles(messages []Message) []string {
+	r := make([]string, len(messages))
+	for i, m := range messages {
+		r[i] = m.Role
+	}
+	return r
+}
+
+func TestRunKeepsRolesAlternatingAfterParseFailure(t *testing.T) {
+	model := &scriptedModel{responses: []string{
+		"I think I should list the files, but forgot the code block.",
+		"```bash\nTASK_COMPLETE\n```",
+	}}
+	a, err := New(model, echo.New(echo.NewConfig()), NewConfig())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), "list the files"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	got := roles(a.Messages())
+	want := []string{RoleSystem, RoleUser, RoleAssistant, RoleUser, RoleAssistant}
+	if !slices.Equal(got, want) {
+		t.Fatalf("roles = %v, want %v", got, want)
+	}
+	if got := a.Messages()[2].Content; got != "I think I should list the files, but forgot the code block." {
+		t.Errorf("malformed response stored as %q, want it kept verbatim", got)
+	}
+}
+
+func TestWriteActionContentKeptOutOfHooksAndAudit(t *testing.T) {
+	const secret = "api_key = s3cr3t"
+	model := &scriptedModel{responses: []string{
+		"```write path=config.toml\n" + secret + "\n```",
+	}}
+	var commands []string
+	var audit bytes.Buffer
+	cfg := NewConfig().
+		WithParser(NewBashParser().WithWriteBlocks(true)).
+		WithMaxSteps(1).
+		WithAuditLog(&audit).
+		WithHooks(Hooks{OnAction: func(action Action) {
+			commands = append(commands, action.Command)
+		}})
+	a, err := New(model, echo.New(echo.NewConfig()), cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	a.Run(context.Background(), "write the config")
+
+	want := []string{"write config.toml (17 bytes)"}
+	if !slices.Equal(commands, want) {
+		t.Errorf("OnAction commands = %q, want %q", commands, want)
+	}
+	if strings.Contains(audit.String(), secret) {
+		t.Errorf("audit log contains the file content: %s", audit.String())
+	}
+	if !strings.Contains(audit.String(), want[0]) {
+		t.Errorf("audit log = %s, want it to record %q", audit.String(), want[0])
+	}
+}
+
+// failingModel fails every query.
+type failingModel struct{ err error }
+
+func (m failingModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	return "", TokenUsage{}, m.err
+}
+
+func TestRunEmitsTerminatedOnUnrecoverableError(t *testing.T) {
+	var reasons []TerminationReason
+	cfg := NewConfig().WithHooks(Hooks{OnTerminate: func(reason TerminationReason) {
+		reasons = append(reasons, reason)
+	}})
+	a, err := New(failingModel{err: errors.New("invalid request")}, echo.New(echo.NewConfig()), cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	events := a.Events()
+
+	if _, err := a.Run(context.Background(), "task"); err == nil {
+		t.Fatal("Run() error = nil, want the model error")
+	}
+
+	var last Event
+	for e := range events {
+		last = e
+	}
+	if last.Type != EventTerminated || last.Reason != ReasonError {
+		t.Errorf("last event = %+v, want terminated with reason %q", last, ReasonError)
+	}
+	if !slices.Equal(reasons, []TerminationReason{ReasonError}) {
+		t.Errorf("OnTerminate reasons = %v, want [%s]", reasons, ReasonError)
+	}
+	if got := a.Result().Reason; got != ReasonError {
+		t.Errorf("Result().Reason = %q, want %q", got, ReasonError)
+	}
+}
+
+func TestRunEmitsCommandStartedBeforeObservation(t *testing.T) {
+	model := &scriptedModel{responses: []string{"```bash\nTASK_COMPLETE\n```"}}
+	a, err := New(model, echo.New(echo.NewConfig()), NewConfig())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	events := a.Events()
+
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	var types []EventType
+	for e := range events {
+		types = append(types, e.Type)
+	}
+	want := []EventType{EventStepStarted, EventCommandStarted, EventObservation, EventTerminated}
+	if !slices.Equal(types, want) {
+		t.Errorf("events = %v, want %v", types, want)
+	}
+}
+
+func TestHistoryReadersAreSafeDuringRun(t *testing.T) {
+	responses := make([]string, 0, 21)
+	for i := range 20 {
+		responses = append(responses, fmt.Sprintf("```bash\necho %d\n```", i))
+	}
+	responses = append(responses, "```bash\nTASK_COMPLETE\n```")
+	a, err := New(&scriptedModel{responses: responses}, echo.New(echo.NewConfig()), NewConfig().WithMaxSteps(25).WithStepDelay(time.Millisecond))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			_ = a.Messages()
+			_ = a.Transcript()
+			_ = a.ExportMessages(io.Discard)
+		}
+	}()
+
+	_, err = a.Run(context.Background(), "count")
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	// System and task, then a reply and an observation per step but the last
+	if got, want := len(a.Messages()), 1+2*len(responses); got != want {
+		t.Errorf("len(Messages()) = %d, want %d", got, want)
+	}
+}
+
+func TestUnsupportedWriteActionIsReportedToModel(t *testing.T) {
+	model := &scriptedModel{responses: []string{
+		"```write path=notes.txt\nhello\n```",
+		"```bash\nTASK_COMPLETE\n```",
+	}}
+	cfg := NewConfig().WithParser(NewBashParser().WithWriteBlocks(true))
+	a, err := New(model, echo.New(echo.NewConfig()), cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), "write notes"); err != nil {
+		t.Fatalf("Run() error = %v, want the write reported and the run to go on", err)
+	}
+	if got := a.Messages()[3].Content; !strings.Contains(got, "not supported") {
+		t.Errorf("observation = %q, want it to explain writes aren't supported", got)
+	}
+}
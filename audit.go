@@ -0,0 +1,82 @@
+package wise
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+// auditRecord is one line of the audit log.
+type auditRecord struct {
+	Time        time.Time `json:"time"`
+	Step        int       `json:"step"`
+	Command     string    `json:"command"`
+	ExitCode    int       `json:"exit_code"`
+	StdoutBytes int       `json:"stdout_bytes"`
+	StderrBytes int       `json:"stderr_bytes"`
+	TimedOut    bool      `json:"timed_out"`
+	DurationMs  int64     `json:"duration_ms"`
+	Blocked     bool      `json:"blocked"`
+	Pattern     string    `json:"pattern,omitempty"`
+	Rejected    bool      `json:"rejected"`
+	Handled     bool      `json:"handled"`
+	Error       string    `json:"error,omitempty"`
+}
+
+// auditLog writes one JSON record per executed action.
+type auditLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// write encodes the record and writes it with its newline in one call.
+func (l *auditLog) write(r auditRecord) error {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(b)
+	return err
+}
+
+// audit records an executed action if an audit log is configured.
+func (a *baseAgent) audit(action Action, output Output, handled bool, err error) {
+	if a.auditLog == nil {
+		return
+	}
+
+	r := auditRecord{
+		Time:        time.Now().UTC(),
+		Step:        a.step + 1,
+		Command:     DisplayCommand(action),
+		ExitCode:    output.ExitCode,
+		StdoutBytes: len(output.Stdout),
+		StderrBytes: len(output.Stderr),
+		TimedOut:    output.TimedOut,
+		DurationMs:  output.Duration.Milliseconds(),
+		Handled:     handled,
+	}
+	if err != nil {
+		r.Error = err.Error()
+
+		var execErr *executor.ExecutionError
+		var procErr *ProcessErr
+		r.Blocked = errors.As(err, &execErr) && execErr.Type == executor.ErrBlocked
+		if r.Blocked {
+			r.Pattern = execErr.Pattern
+		}
+		r.Rejected = errors.As(err, &procErr) && procErr.Type == ProcessErrRejected
+	}
+
+	if werr := a.auditLog.write(r); werr != nil {
+		a.execLog.Error().Err(werr).Msg("failed to write audit record")
+	}
+}
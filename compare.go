@@ -0,0 +1,101 @@
+package wise
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/models"
+)
+
+// Contender is a model entered in a Compare run.
+type Contender struct {
+	Name  string
+	Model models.Model
+}
+
+// Comparison is one contender's outcome in a Compare run.
+type Comparison struct {
+	Name   string
+	Dir    string // Working directory the run used
+	Result Result
+}
+
+// Compare runs task once per contender, concurrently, each in its own
+// temporary working directory created for newEnv. Results are ordered
+// best first: completed runs before the rest, then by fewest steps, then
+// by lowest cost.
+//
+// The directories are kept so the runs can be inspected; the caller
+// removes them. A contender whose environment can't be created has its
+// directory removed and an empty Dir. cfg is shared by every run, so its hooks and writers
+// must be safe for concurrent use.
+func Compare(ctx context.Context, task string, contenders []Contender, newEnv func(dir string) (executor.Environment, error), cfg Config) ([]Comparison, error) {
+	results := make([]Comparison, len(contenders))
+	for i, c := range contenders {
+		dir, err := os.MkdirTemp("", "wise-compare-*")
+		if err != nil {
+			for _, r := range results[:i] {
+				os.RemoveAll(r.Dir)
+			}
+			return nil, fmt.Errorf("failed to create working dir for %s: %w", c.Name, err)
+		}
+		results[i] = Comparison{Name: c.Name, Dir: dir}
+	}
+
+	var wg sync.WaitGroup
+	for i, c := range contenders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			runContender(ctx, task, c.Model, &results[i], newEnv, cfg)
+		}()
+	}
+	wg.Wait()
+
+	slices.SortStableFunc(results, func(a, b Comparison) int {
+		if a.Result.Completed() != b.Result.Completed() {
+			if a.Result.Completed() {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Or(
+			cmp.Compare(a.Result.Steps, b.Result.Steps),
+			cmp.Compare(a.Result.Cost, b.Result.Cost),
+		)
+	})
+	return results, nil
+}
+
+// runContender runs task with model in c.Dir and records its outcome.
+// If the run can't be set up, the directory is removed and c.Dir cleared.
+func runContender(ctx context.Context, task string, model models.Model, c *Comparison, newEnv func(dir string) (executor.Environment, error), cfg Config) {
+	env, err := newEnv(c.Dir)
+	if err != nil {
+		os.RemoveAll(c.Dir)
+		c.Dir = ""
+		c.Result = Result{Err: fmt.Errorf("failed to create environment: %w", err)}
+		return
+	}
+
+	agent, err := New(model, env, cfg)
+	if err != nil {
+		if closer, ok := env.(io.Closer); ok {
+			closer.Close()
+		}
+		os.RemoveAll(c.Dir)
+		c.Dir = ""
+		c.Result = Result{Err: fmt.Errorf("failed to create agent: %w", err)}
+		return
+	}
+	defer agent.Close()
+
+	agent.Run(ctx, task)
+	c.Result = agent.Result()
+}
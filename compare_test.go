@@ -0,0 +1,56 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/executor/echo"
+)
+
+func TestCompareRemovesDirWhenEnvironmentFails(t *testing.T) {
+	contenders := []Contender{
+		{Name: "a", Model: &scriptedModel{responses: []string{"```bash\nTASK_COMPLETE\n```"}}},
+		{Name: "b", Model: &scriptedModel{responses: []string{"```bash\nTASK_COMPLETE\n```"}}},
+	}
+	// The first environment requested fails
+	var mu sync.Mutex
+	var broken string
+	newEnv := func(dir string) (executor.Environment, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if broken == "" {
+			broken = dir
+			return nil, errors.New("no sandbox")
+		}
+		return echo.New(echo.NewConfig()), nil
+	}
+
+	results, err := Compare(context.Background(), "task", contenders, newEnv, NewConfig())
+	if err != nil {
+		t.Fatalf("Compare() error = %v", err)
+	}
+	for _, r := range results {
+		if r.Dir != "" {
+			defer os.RemoveAll(r.Dir)
+		}
+	}
+
+	if _, err := os.Stat(broken); !os.IsNotExist(err) {
+		t.Errorf("working dir of the failed contender still exists: %v", err)
+	}
+	failed := results[len(results)-1]
+	if failed.Dir != "" {
+		t.Errorf("failed contender Dir = %q, want empty", failed.Dir)
+	}
+	if failed.Result.Err == nil || !strings.HasPrefix(failed.Result.Err.Error(), "failed to create environment") {
+		t.Errorf("failed contender Err = %v, want a failed to create environment error", failed.Result.Err)
+	}
+	if !results[0].Result.Completed() {
+		t.Errorf("other contender didn't complete: %+v", results[0].Result)
+	}
+}
@@ -0,0 +1,90 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+// lockedWriter serializes writes from concurrently running commands.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (l *lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
+
+// executeConcurrent runs independent actions with at most
+// cfg.concurrency in flight. Observations are reported in the order the
+// model gave the commands, followed by a summary of the failures.
+func (a *baseAgent) executeConcurrent(ctx context.Context, actions []Action) (string, error) {
+	type result struct {
+		output Output
+		err    error
+	}
+	results := make([]result, len(actions))
+
+	sem := make(chan struct{}, a.cfg.concurrency)
+	var wg sync.WaitGroup
+	for i, action := range actions {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			output, err := a.execute(ctx, action)
+			results[i] = result{output: output, err: err}
+		}()
+	}
+	wg.Wait()
+
+	parts := make([]string, 0, len(actions)+1)
+	var failed []string
+
+	for i, r := range results {
+		command := DisplayCommand(actions[i])
+
+		var execErr *executor.ExecutionError
+		var procErr *ProcessErr
+		switch {
+		case errors.As(r.err, &execErr):
+			parts = append(parts, fmt.Sprintf("$ %s\n%s", command, execErr.Message))
+		case errors.As(r.err, &procErr):
+			parts = append(parts, fmt.Sprintf("$ %s\n%s", command, procErr.Message))
+		case r.err != nil:
+			return "", r.err
+		default:
+			a.reportOutput(r.output)
+			if final, done := a.checkCompletion(r.output); done {
+				return a.complete(actions[i], final)
+			}
+			parts = append(parts, fmt.Sprintf("$ %s\n%s", command, a.observation(ctx, r.output)))
+		}
+
+		switch {
+		case r.output.TimedOut:
+			failed = append(failed, fmt.Sprintf("#%d timed out", i+1))
+		case r.output.ExitCode != 0:
+			failed = append(failed, fmt.Sprintf("#%d exit %d", i+1, r.output.ExitCode))
+		case r.err != nil:
+			failed = append(failed, fmt.Sprintf("#%d error", i+1))
+		}
+	}
+
+	if len(failed) > 0 {
+		parts = append(parts, fmt.Sprintf("[%d of %d command(s) failed: %s]", len(failed), len(actions), strings.Join(failed, ", ")))
+	}
+
+	a.addFeedback(strings.Join(parts, "\n\n"))
+
+	return "", nil
+}
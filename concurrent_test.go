@@ -0,0 +1,109 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// delayEnv answers each command with a scripted output after a delay and
+// tracks how many commands run at once.
+type delayEnv struct {
+	outputs map[string]Output
+	delays  map[string]time.Duration
+
+	mu          sync.Mutex
+	running     int
+	maxInFlight int
+}
+
+func (e *delayEnv) Execute(ctx context.Context, action Action) (Output, error) {
+	e.mu.Lock()
+	e.running++
+	e.maxInFlight = max(e.maxInFlight, e.running)
+	e.mu.Unlock()
+	defer func() {
+		e.mu.Lock()
+		e.running--
+		e.mu.Unlock()
+	}()
+
+	time.Sleep(e.delays[action.Command])
+	return e.outputs[action.Command], nil
+}
+
+func blocks(commands ...string) string {
+	var b strings.Builder
+	for _, c := range commands {
+		b.WriteString("```bash\n" + c + "\n```\n")
+	}
+	return b.String()
+}
+
+func TestConcurrentObservationsKeepCommandOrder(t *testing.T) {
+	env := &delayEnv{
+		outputs: map[string]Output{
+			"slow":  {Stdout: "slow done"},
+			"fails": {Stdout: "broken", ExitCode: 2},
+			"fast":  {Stdout: "fast done"},
+			"hangs": {Stdout: "late", TimedOut: true, ExitCode: -1},
+		},
+		delays: map[string]time.Duration{"slow": 100 * time.Millisecond, "fails": 50 * time.Millisecond},
+	}
+	model := &scriptedModel{responses: []string{blocks("slow", "fails", "fast", "hangs")}}
+	a, err := New(model, env, NewConfig().WithMultiCommand(true).WithConcurrency(2).WithMaxSteps(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, err = a.Run(context.Background(), "run the checks")
+	var te *TerminatingErr
+	if !errors.As(err, &te) || te.Reason != ReasonStepLimit {
+		t.Fatalf("Run() error = %v, want the step limit", err)
+	}
+
+	messages := a.Messages()
+	observation := messages[len(messages)-1].Content
+	var last int
+	for _, want := range []string{"$ slow\n", "$ fails\n", "$ fast\n", "$ hangs\n"} {
+		i := strings.Index(observation, want)
+		if i < last {
+			t.Fatalf("observation = %q, want %q after the commands before it", observation, want)
+		}
+		last = i
+	}
+	if !strings.Contains(observation, "[2 of 4 command(s) failed: #2 exit 2, #4 timed out]") {
+		t.Errorf("observation = %q, want a summary of the failures", observation)
+	}
+	if env.maxInFlight != 2 {
+		t.Errorf("max commands in flight = %d, want the concurrency of 2", env.maxInFlight)
+	}
+}
+
+func TestConcurrentCompletionEndsRun(t *testing.T) {
+	env := &delayEnv{
+		outputs: map[string]Output{
+			"build":         {Stdout: "ok"},
+			"TASK_COMPLETE": {Stdout: "TASK_COMPLETE\nall built"},
+			"cleanup":       {Stdout: "ok"},
+		},
+	}
+	model := &scriptedModel{responses: []string{blocks("build", "TASK_COMPLETE", "cleanup")}}
+	a, err := New(model, env, NewConfig().WithMultiCommand(true).WithConcurrency(3))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	// The scripted model has no second response, so a run that didn't
+	// stop at the marker would fail
+	output, err := a.Run(context.Background(), "build it")
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if output != "all built" {
+		t.Errorf("Run() = %q, want the completing command's output", output)
+	}
+}
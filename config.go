@@ -2,10 +2,17 @@ package wise
 
 import (
 	"io"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// DefaultCompletionMarker is the output line that signals task completion.
+const DefaultCompletionMarker = "TASK_COMPLETE"
+
 // DefaultSystemPrompt is the default system prompt for the agent.
 const DefaultSystemPrompt = `You are an autonomous agent that executes bash commands to complete tasks.
 
@@ -26,15 +33,73 @@ echo "TASK_COMPLETE"
 echo "Summary: Created hello.txt with the requested content"
 ` + "```"
 
+// DefaultJSONSystemPrompt is a system prompt for use with JSONParser.
+const DefaultJSONSystemPrompt = `You are an autonomous agent that executes bash commands to complete tasks.
+
+RULES:
+1. You can ONLY execute bash commands by responding with a JSON object: {"action": "bash", "command": "<command>"}
+2. Execute ONE command at a time and wait for the output
+3. Use the command output to inform your next action
+4. When the task is complete, run a command that outputs "TASK_COMPLETE" followed by a summary on the next line
+
+Example command:
+{"action": "bash", "command": "ls -la"}
+
+Example completion:
+{"action": "bash", "command": "echo TASK_COMPLETE; echo 'Summary: Created hello.txt with the requested content'"}`
+
+// DefaultMaxObservationBytes is the default command output size fed back
+// to the model before truncation.
+const DefaultMaxObservationBytes = 10000
+
+// DefaultMaxEmptyResponses is the default number of consecutive empty
+// model responses after which a run fails.
+const DefaultMaxEmptyResponses = 3
+
 // Config holds the agent configuration (optional settings only).
 type Config struct {
-	parser        Parser
-	logger        *zerolog.Logger
-	output        io.Writer
-	maxSteps      int
-	contextLimit  int
-	systemPrompt  string
-	actionHandler ActionHandler
+	parser           Parser
+	logger           *zerolog.Logger
+	output           io.Writer
+	commandWriter    io.Writer
+	maxSteps         int
+	contextLimit     int
+	systemPrompt     string
+	actionHandlers   []ActionHandler
+	ctxHandler       ContextActionHandler
+	tools            []DescribedActionHandler
+	logLevels        map[string]zerolog.Level
+	streaming        bool
+	showStderr       bool
+	maxCost          float64
+	runTimeout       time.Duration
+	pricing          map[string]Price
+	retryAttempts    int
+	retryDelay       time.Duration
+	stepDelay        time.Duration
+	multiCommand     bool
+	approval         ApprovalFunc
+	auditLog         io.Writer
+	hooks            Hooks
+	memory           MemoryStrategy
+	maxObsBytes      *int
+	maxEmpty         *int
+	inputs           map[string][]byte
+	obsTemplates     ObservationTemplates
+	dedupObs         bool
+	confirmDone      bool
+	termCheck        TerminationCheck
+	cwdInObs         bool
+	fatalExitCodes   []int
+	stepBudgetHint   bool
+	dryRun           bool
+	completionMarker string
+	eventBuffer      int
+	tracer           trace.Tracer
+	concurrency      int
+	gracefulShutdown bool
+	userPrompt       string
+	promptEnv        []string
 }
 
 // NewConfig creates a new Config with sensible defaults.
@@ -52,7 +117,9 @@ func (c Config) WithParser(p Parser) Config {
 	return c
 }
 
-// WithLogger sets the logger.
+// WithLogger sets the logger the agent and its model and executor
+// components log to, e.g. one writing to a file or a structured sink.
+// Defaults to a no-op logger. See WithLogLevel for per-component levels.
 func (c Config) WithLogger(l *zerolog.Logger) Config {
 	c.logger = l
 	return c
@@ -64,6 +131,13 @@ func (c Config) WithOutput(w io.Writer) Config {
 	return c
 }
 
+// WithCommandWriter sets where the "$ command" echo is written, separately
+// from command output. Defaults to the main output writer.
+func (c Config) WithCommandWriter(w io.Writer) Config {
+	c.commandWriter = w
+	return c
+}
+
 // WithMaxSteps sets the maximum number of agent steps.
 func (c Config) WithMaxSteps(n int) Config {
 	c.maxSteps = n
@@ -76,14 +150,304 @@ func (c Config) WithContextLimit(n int) Config {
 	return c
 }
 
-// WithSystemPrompt sets the system prompt.
+// WithSystemPrompt sets the system prompt. Prompts are text/template
+// templates over PromptData, e.g. {{.WorkingDir}}; write {{"{{"}} for
+// literal braces.
 func (c Config) WithSystemPrompt(p string) Config {
 	c.systemPrompt = p
 	return c
 }
 
+// WithUserPrompt sets a template for the first user message, wrapping the
+// task passed to Run, e.g. "Task: {{.Task}}\nYou are on {{.OS}}.".
+func (c Config) WithUserPrompt(prompt string) Config {
+	c.userPrompt = prompt
+	return c
+}
+
+// WithPromptEnv exposes the named environment variables to prompt
+// templates as {{.Env.NAME}}. Other variables are never exposed.
+func (c Config) WithPromptEnv(names ...string) Config {
+	c.promptEnv = append([]string(nil), names...)
+	return c
+}
+
 // WithActionHandler sets a custom action handler for extensibility.
 func (c Config) WithActionHandler(h ActionHandler) Config {
-	c.actionHandler = h
+	if h == nil {
+		c.actionHandlers = nil
+		return c
+	}
+	c.actionHandlers = []ActionHandler{h}
+	return c
+}
+
+// WithActionHandlers sets several action handlers, tried in order until
+// one handles the action. It replaces any handler set before.
+func (c Config) WithActionHandlers(handlers ...ActionHandler) Config {
+	c.actionHandlers = slices.Clone(handlers)
+	return c
+}
+
+// WithDescribedActionHandlers sets handlers for tools that describe
+// themselves. They are tried in order after the plain action handlers,
+// and listed with their descriptions under "Available tools:" at the end
+// of the system prompt, keeping the prompt in sync with the handlers. It
+// replaces any described handlers set before.
+func (c Config) WithDescribedActionHandlers(handlers ...DescribedActionHandler) Config {
+	c.tools = slices.Clone(handlers)
+	return c
+}
+
+// WithActionHandlerContext sets an action handler that can also read and
+// add messages. It runs after the approval gate and before the plain
+// action handlers; unhandled actions fall through to those and then to
+// the environment.
+func (c Config) WithActionHandlerContext(h ContextActionHandler) Config {
+	c.ctxHandler = h
+	return c
+}
+
+// WithLogLevel sets the minimum log level for a component
+// (ComponentAgent, ComponentModel or ComponentExecutor).
+func (c Config) WithLogLevel(component string, level zerolog.Level) Config {
+	levels := make(map[string]zerolog.Level, len(c.logLevels)+1)
+	for k, v := range c.logLevels {
+		levels[k] = v
+	}
+	levels[component] = level
+	c.logLevels = levels
+	return c
+}
+
+// WithStreaming streams model output to the output writer as it arrives.
+// Models that don't implement models.StreamingModel are queried as usual.
+func (c Config) WithStreaming(enabled bool) Config {
+	c.streaming = enabled
+	return c
+}
+
+// WithShowStderr also prints command stderr to the output writer, each
+// line prefixed with "stderr: ". It is always fed to the model either way.
+func (c Config) WithShowStderr(enabled bool) Config {
+	c.showStderr = enabled
+	return c
+}
+
+// WithMaxCost sets the maximum estimated spend in dollars for a run.
+// Requires pricing for the model, see WithPricing.
+func (c Config) WithMaxCost(dollars float64) Config {
+	c.maxCost = dollars
+	return c
+}
+
+// WithRunTimeout bounds each Run, Continue or Chat call as a whole, on
+// top of the per-command timeout. When it fires, the in-flight query or
+// command is cancelled and the run ends with ReasonRunTimeout and the
+// partial output. No summary step is taken, even with graceful shutdown.
+func (c Config) WithRunTimeout(d time.Duration) Config {
+	c.runTimeout = d
+	return c
+}
+
+// WithPricing sets the pricing table keyed by model name.
+func (c Config) WithPricing(pricing map[string]Price) Config {
+	c.pricing = make(map[string]Price, len(pricing))
+	for k, v := range pricing {
+		c.pricing[k] = v
+	}
+	return c
+}
+
+// WithRetry retries transient model query failures up to maxAttempts
+// total attempts, with exponential backoff starting at baseDelay and
+// capped at MaxRetryDelay. When streaming, a retry after a partly
+// streamed response is marked in the output before the new response.
+func (c Config) WithRetry(maxAttempts int, baseDelay time.Duration) Config {
+	c.retryAttempts = maxAttempts
+	c.retryDelay = baseDelay
+	return c
+}
+
+// WithStepDelay pauses between steps, as a simple throttle on model
+// requests. Zero, the default, means no delay.
+func (c Config) WithStepDelay(d time.Duration) Config {
+	c.stepDelay = d
+	return c
+}
+
+// WithMultiCommand lets a single response carry several commands, run in
+// order and stopping at the first failure. Uses MultiBashParser unless a
+// parser implementing MultiParser is set.
+func (c Config) WithMultiCommand(enabled bool) Config {
+	c.multiCommand = enabled
+	return c
+}
+
+// WithConcurrency runs the commands of a multi-command response in
+// parallel, at most n at a time, instead of one after another with a stop
+// at the first failure. Use it when the model's commands are independent.
+// Hooks and the approval gate may then be called from several goroutines;
+// approval calls are serialized.
+func (c Config) WithConcurrency(n int) Config {
+	c.concurrency = n
+	return c
+}
+
+// WithApproval sets a gate called before each action runs, ahead of the
+// action handler and the environment.
+func (c Config) WithApproval(f ApprovalFunc) Config {
+	c.approval = f
+	return c
+}
+
+// WithAuditLog writes one JSON line per executed action to w, including
+// failed, blocked and rejected ones. Flushing and rotation are up to w.
+func (c Config) WithAuditLog(w io.Writer) Config {
+	c.auditLog = w
+	return c
+}
+
+// WithHooks sets callbacks for observing the agent's progress.
+func (c Config) WithHooks(h Hooks) Config {
+	c.hooks = h
+	return c
+}
+
+// WithMaxContextMessages keeps at most n messages in the conversation,
+// dropping the oldest after the system prompt and task.
+func (c Config) WithMaxContextMessages(n int) Config {
+	c.memory = NewDropOldestMemory(n)
+	return c
+}
+
+// WithMemoryStrategy sets how the conversation is reduced before each query.
+func (c Config) WithMemoryStrategy(m MemoryStrategy) Config {
+	c.memory = m
+	return c
+}
+
+// WithMaxObservationBytes sets how much command output is fed back to the
+// model; longer output keeps its head and tail. Zero disables truncation.
+func (c Config) WithMaxObservationBytes(n int) Config {
+	c.maxObsBytes = &n
+	return c
+}
+
+// WithMaxEmptyResponses fails the run with ErrEmptyResponses after n
+// consecutive empty model responses, which usually mean the provider is
+// in a bad state. Zero disables the check.
+func (c Config) WithMaxEmptyResponses(n int) Config {
+	c.maxEmpty = &n
+	return c
+}
+
+// WithInputs supplies out-of-band content that commands reference as
+// #INPUT:name, keeping large payloads out of the conversation. The local
+// environment writes each referenced input to a private temporary file
+// and substitutes its path before running the command. Input names are
+// listed in PromptData.Inputs for use in prompts.
+func (c Config) WithInputs(inputs map[string][]byte) Config {
+	c.inputs = maps.Clone(inputs)
+	return c
+}
+
+// WithObservationTemplates changes how command results are phrased for
+// the model, e.g. to suit a smaller model. Empty fields keep the defaults
+// from DefaultObservationTemplates.
+func (c Config) WithObservationTemplates(t ObservationTemplates) Config {
+	c.obsTemplates = t
+	return c
+}
+
+// WithDedupObservations replaces command output identical to an earlier
+// step's with a short note naming that step, to save tokens. Off by
+// default since it changes what the model sees.
+func (c Config) WithDedupObservations(enabled bool) Config {
+	c.dedupObs = enabled
+	return c
+}
+
+// WithCompletionConfirmation questions the model's first completion
+// signal in each run, asking it to check the whole task is done. Only a
+// second signal ends the run, which curbs premature completion of
+// compound tasks.
+func (c Config) WithCompletionConfirmation(enabled bool) Config {
+	c.confirmDone = enabled
+	return c
+}
+
+// WithTerminationCheck adds custom completion logic, run after each
+// command alongside the completion marker check, e.g. to stop once a
+// build succeeds.
+func (c Config) WithTerminationCheck(check TerminationCheck) Config {
+	c.termCheck = check
+	return c
+}
+
+// WithCwdInObservation prefixes each observation with the environment's
+// working directory, e.g. "[cwd: /work]", to keep the model oriented.
+// Only environments implementing executor.WorkingDirReporter, such as
+// the local one, report it.
+func (c Config) WithCwdInObservation(enabled bool) Config {
+	c.cwdInObs = enabled
+	return c
+}
+
+// WithFatalExitCodes ends the run with ErrFatalExitCode when a command
+// exits with one of codes, e.g. 126 or 127, which point to a broken
+// environment rather than a mistake the model can correct. By default
+// every exit code is fed back to the model.
+func (c Config) WithFatalExitCodes(codes ...int) Config {
+	c.fatalExitCodes = slices.Clone(codes)
+	return c
+}
+
+// WithStepBudgetHint ends each observation with the number of steps
+// left, e.g. "[steps remaining: 3]", so the model can wrap up before the
+// limit.
+func (c Config) WithStepBudgetHint(enabled bool) Config {
+	c.stepBudgetHint = enabled
+	return c
+}
+
+// WithDryRun prints commands and feeds back a placeholder observation
+// instead of executing them, to preview the agent's plan.
+func (c Config) WithDryRun(enabled bool) Config {
+	c.dryRun = enabled
+	return c
+}
+
+// WithCompletionMarker sets the output line that signals task completion.
+// An empty marker keeps the default. The built-in system prompts and
+// parser feedback are updated to match; custom prompts must mention the
+// marker themselves.
+func (c Config) WithCompletionMarker(marker string) Config {
+	c.completionMarker = marker
+	return c
+}
+
+// WithEventBuffer sets the capacity of the channel returned by Events.
+func (c Config) WithEventBuffer(n int) Config {
+	c.eventBuffer = n
+	return c
+}
+
+// WithTracer enables OpenTelemetry spans for runs, steps, model queries
+// and commands. Spans are children of the span in the caller's context.
+func (c Config) WithTracer(tracer trace.Tracer) Config {
+	c.tracer = tracer
+	return c
+}
+
+// WithGracefulShutdown makes a cancelled run ask the model for a summary
+// of its progress in one final step, bounded by GracefulShutdownTimeout,
+// instead of returning a bare context error. The run then ends with
+// ReasonUserAbort and the summary as its output.
+//
+// The final step ignores ctx cancellation. Pass a force-stop context with
+// WithForceStop to abort it, e.g. on a second interrupt.
+func (c Config) WithGracefulShutdown(enabled bool) Config {
+	c.gracefulShutdown = enabled
 	return c
 }
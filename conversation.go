@@ -0,0 +1,15 @@
+package wise
+
+// conversation exposes the agent's messages to context action handlers.
+// Handlers may run concurrently, so access is serialized.
+type conversation struct {
+	a *baseAgent
+}
+
+func (c conversation) Messages() []Message {
+	return c.a.history()
+}
+
+func (c conversation) AddMessage(role, content string) {
+	c.a.addMessage(role, content)
+}
@@ -0,0 +1,12 @@
+package wise
+
+// Price holds model pricing in dollars per million tokens.
+type Price struct {
+	Prompt     float64
+	Completion float64
+}
+
+// Cost returns the estimated dollar cost of the given token usage.
+func (p Price) Cost(u TokenUsage) float64 {
+	return (float64(u.PromptTokens)*p.Prompt + float64(u.CompletionTokens)*p.Completion) / 1_000_000
+}
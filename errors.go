@@ -9,28 +9,42 @@ import (
 var (
 	ErrModelRequired       = errors.New("model is required")
 	ErrEnvironmentRequired = errors.New("environment is required")
+	ErrNoConversation      = errors.New("no conversation to continue")
+	ErrEmptyResponses      = errors.New("model returned empty responses")
+	ErrFatalExitCode       = errors.New("fatal exit code")
+	ErrRunTimeout          = errors.New("run timeout exceeded")
 )
 
 // TerminationReason indicates why the agent stopped.
 type TerminationReason string
 
 const (
-	ReasonComplete  TerminationReason = "complete"
-	ReasonStepLimit TerminationReason = "step_limit"
-	ReasonCostLimit TerminationReason = "cost_limit"
-	ReasonUserAbort TerminationReason = "user_abort"
+	ReasonComplete   TerminationReason = "complete"
+	ReasonStepLimit  TerminationReason = "step_limit"
+	ReasonCostLimit  TerminationReason = "cost_limit"
+	ReasonUserAbort  TerminationReason = "user_abort"
+	ReasonRunTimeout TerminationReason = "run_timeout"
+	ReasonError      TerminationReason = "error" // Unrecoverable error
 )
 
 // TerminatingErr signals the agent should stop the loop.
 type TerminatingErr struct {
 	Reason TerminationReason
 	Output string // Optional final output
+	Err    error  // Underlying cause, e.g. the context error of a user abort
 }
 
 func (e *TerminatingErr) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("terminating: %s: %v", e.Reason, e.Err)
+	}
 	return fmt.Sprintf("terminating: %s", e.Reason)
 }
 
+func (e *TerminatingErr) Unwrap() error {
+	return e.Err
+}
+
 // ProcessErrType indicates the type of recoverable error.
 type ProcessErrType string
 
@@ -38,6 +52,7 @@ const (
 	ProcessErrFormat    ProcessErrType = "format"
 	ProcessErrTimeout   ProcessErrType = "timeout"
 	ProcessErrExecution ProcessErrType = "execution"
+	ProcessErrRejected  ProcessErrType = "rejected"
 )
 
 // ProcessErr signals a recoverable error. The agent should add
@@ -0,0 +1,95 @@
+package wise
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+const (
+	EventStepStarted    EventType = "step_started"
+	EventCommandStarted EventType = "command_started"
+	EventObservation    EventType = "observation"
+	EventTerminated     EventType = "terminated"
+)
+
+// Event is a progress update from the agent. Which fields are set
+// depends on Type: Action for EventCommandStarted, Output for
+// EventObservation and Reason for EventTerminated. A write action's
+// Command holds its DisplayCommand rather than the file content.
+type Event struct {
+	Type   EventType
+	Step   int
+	Action Action
+	Output Output
+	Reason TerminationReason
+}
+
+// DefaultEventBuffer is the default capacity of the events channel.
+const DefaultEventBuffer = 64
+
+// Events returns a channel of progress events for the current or next
+// run. The channel is closed when Run or Continue returns; call Events
+// again for the following run. Sends never block the agent: events are
+// dropped when the buffer (see Config.WithEventBuffer) is full, so
+// callers should drain the channel promptly.
+func (a *baseAgent) Events() <-chan Event {
+	a.eventsMu.Lock()
+	defer a.eventsMu.Unlock()
+
+	if a.events == nil {
+		a.events = make(chan Event, a.cfg.eventBuffer)
+	}
+	return a.events
+}
+
+// emit sends an event without blocking, if anyone asked for events.
+func (a *baseAgent) emit(e Event) {
+	a.eventsMu.Lock()
+	defer a.eventsMu.Unlock()
+
+	if a.events == nil {
+		return
+	}
+	e.Step = a.step + 1
+	select {
+	case a.events <- e:
+	default:
+		a.cfg.logger.Debug().
+			Str("event", string(e.Type)).
+			Msg("event buffer full, dropping event")
+	}
+}
+
+// closeEvents closes the events channel at the end of a run.
+func (a *baseAgent) closeEvents() {
+	a.eventsMu.Lock()
+	defer a.eventsMu.Unlock()
+
+	if a.events != nil {
+		close(a.events)
+		a.events = nil
+	}
+}
+
+// notifyStepStart reports the start of a step to hooks and events.
+func (a *baseAgent) notifyStepStart() {
+	a.cfg.hooks.stepStart(a.step + 1)
+	a.emit(Event{Type: EventStepStarted})
+}
+
+// notifyAction reports a command about to run to hooks and events.
+func (a *baseAgent) notifyAction(action Action) {
+	action = displayAction(action)
+	a.cfg.hooks.action(action)
+	a.emit(Event{Type: EventCommandStarted, Action: action})
+}
+
+// notifyObservation reports command output to hooks and events.
+func (a *baseAgent) notifyObservation(output Output) {
+	a.cfg.hooks.observation(output)
+	a.emit(Event{Type: EventObservation, Output: output})
+}
+
+// notifyTerminate reports termination to hooks and events.
+func (a *baseAgent) notifyTerminate(reason TerminationReason) {
+	a.cfg.hooks.terminate(reason)
+	a.emit(Event{Type: EventTerminated, Reason: reason})
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/j0lvera/wise"
+
+	"github.com/spf13/cobra"
+)
+
+// ResultFormatter renders a run for the --format flag.
+type ResultFormatter interface {
+	// Event is called for each agent event while the run is in progress.
+	Event(w io.Writer, e wise.Event) error
+	// Result is called once the run has ended.
+	Result(w io.Writer, task string, messages []wise.Message, r wise.Result) error
+}
+
+// formatters maps --format values to their formatter.
+var formatters = map[string]ResultFormatter{
+	"text":     textFormatter{},
+	"json":     jsonFormatter{},
+	"markdown": markdownFormatter{},
+	"ndjson":   ndjsonFormatter{},
+}
+
+// formatFlag returns the selected format name. --format supersedes the
+// older --json flag.
+func formatFlag(cmd *cobra.Command) string {
+	if f := cmd.Flags().Lookup("format"); f != nil && f.Changed {
+		return f.Value.String()
+	}
+	if jsonOut, _ := cmd.Flags().GetBool("json"); jsonOut {
+		return "json"
+	}
+	return "text"
+}
+
+// formatter returns the formatter selected by the command's flags.
+func formatter(cmd *cobra.Command) (ResultFormatter, error) {
+	name := formatFlag(cmd)
+	f, ok := formatters[name]
+	if !ok {
+		return nil, &configError{fmt.Errorf("unknown format %q, expected text, json, markdown or ndjson", name)}
+	}
+	return f, nil
+}
+
+// textFormatter prints the final output, as the agent's live output
+// already shows the commands.
+type textFormatter struct{}
+
+func (textFormatter) Event(io.Writer, wise.Event) error { return nil }
+
+func (textFormatter) Result(w io.Writer, _ string, _ []wise.Message, r wise.Result) error {
+	if r.Output == "" {
+		return nil
+	}
+	_, err := fmt.Fprintln(w, r.Output)
+	return err
+}
+
+// jsonFormatter prints a RunResult.
+type jsonFormatter struct{}
+
+func (jsonFormatter) Event(io.Writer, wise.Event) error { return nil }
+
+func (jsonFormatter) Result(w io.Writer, task string, _ []wise.Message, r wise.Result) error {
+	return writeJSON(w, newRunResult(task, r))
+}
+
+// markdownFormatter renders the transcript as a Markdown document.
+type markdownFormatter struct{}
+
+func (markdownFormatter) Event(io.Writer, wise.Event) error { return nil }
+
+func (markdownFormatter) Result(w io.Writer, task string, messages []wise.Message, r wise.Result) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", task)
+
+	step := 0
+	for _, m := range messages {
+		switch m.Role {
+		case wise.RoleAssistant:
+			step++
+			fmt.Fprintf(&b, "## Step %d\n\n%s\n\n", step, m.Content)
+		case wise.RoleUser:
+			if step == 0 {
+				continue // The task itself
+			}
+			fmt.Fprintf(&b, "**Observation**\n\n```\n%s\n```\n\n", strings.TrimRight(m.Content, "\n"))
+		}
+	}
+
+	fmt.Fprintf(&b, "## Result\n\n")
+	if r.Reason != "" {
+		fmt.Fprintf(&b, "- Reason: %s\n", r.Reason)
+	}
+	fmt.Fprintf(&b, "- Steps: %d\n", r.Steps)
+	if r.Usage.TotalTokens > 0 {
+		fmt.Fprintf(&b, "- Tokens: %d\n", r.Usage.TotalTokens)
+	}
+	if r.Cost > 0 {
+		fmt.Fprintf(&b, "- Cost: $%.4f\n", r.Cost)
+	}
+	if r.Output != "" {
+		fmt.Fprintf(&b, "\n%s\n", r.Output)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// ndjsonFormatter streams one JSON object per event, then the result.
+type ndjsonFormatter struct{}
+
+// ndjsonEvent is one line of ndjson output.
+type ndjsonEvent struct {
+	Type     string `json:"type"`
+	Step     int    `json:"step,omitempty"`
+	Command  string `json:"command,omitempty"`
+	Stdout   string `json:"stdout,omitempty"`
+	Stderr   string `json:"stderr,omitempty"`
+	ExitCode int    `json:"exit_code,omitempty"`
+	TimedOut bool   `json:"timed_out,omitempty"`
+	Duration int64  `json:"duration_ms,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+func (ndjsonFormatter) Event(w io.Writer, e wise.Event) error {
+	line := ndjsonEvent{
+		Type:   string(e.Type),
+		Step:   e.Step,
+		Reason: string(e.Reason),
+	}
+	switch e.Type {
+	case wise.EventCommandStarted:
+		line.Command = e.Action.Command
+	case wise.EventObservation:
+		line.Stdout = e.Output.Stdout
+		line.Stderr = e.Output.Stderr
+		line.ExitCode = e.Output.ExitCode
+		line.TimedOut = e.Output.TimedOut
+		line.Duration = e.Output.Duration.Milliseconds()
+	}
+	return json.NewEncoder(w).Encode(line)
+}
+
+func (ndjsonFormatter) Result(w io.Writer, task string, _ []wise.Message, r wise.Result) error {
+	return json.NewEncoder(w).Encode(struct {
+		Type string `json:"type"`
+		RunResult
+	}{Type: "result", RunResult: newRunResult(task, r)})
+}
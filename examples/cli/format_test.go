@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise"
+
+	"github.com/spf13/cobra"
+)
+
+func formatCommand(t *testing.T, args ...string) *cobra.Command {
+	t.Helper()
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("json", false, "")
+	cmd.Flags().String("format", "text", "")
+	if err := cmd.Flags().Parse(args); err != nil {
+		t.Fatalf("Parse(%v) error = %v", args, err)
+	}
+	return cmd
+}
+
+func TestFormatterSelection(t *testing.T) {
+	tests := []struct {
+		args []string
+		want ResultFormatter
+	}{
+		{nil, textFormatter{}},
+		{[]string{"--json"}, jsonFormatter{}},
+		{[]string{"--format", "markdown"}, markdownFormatter{}},
+		{[]string{"--json", "--format", "ndjson"}, ndjsonFormatter{}},
+		{[]string{"--json", "--format", "text"}, textFormatter{}},
+	}
+	for _, tt := range tests {
+		t.Run(strings.Join(tt.args, " "), func(t *testing.T) {
+			got, err := formatter(formatCommand(t, tt.args...))
+			if err != nil {
+				t.Fatalf("formatter() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("formatter() = %T, want %T", got, tt.want)
+			}
+		})
+	}
+
+	_, err := formatter(formatCommand(t, "--format", "yaml"))
+	var cfgErr *configError
+	if !errors.As(err, &cfgErr) {
+		t.Errorf("formatter(yaml) error = %v, want a configError", err)
+	}
+}
+
+func TestMarkdownFormatter(t *testing.T) {
+	messages := []wise.Message{
+		{Role: wise.RoleSystem, Content: "You are a shell agent."},
+		{Role: wise.RoleUser, Content: "count the files"},
+		{Role: wise.RoleAssistant, Content: "```bash\nls | wc -l\n```"},
+		{Role: wise.RoleUser, Content: "3\n"},
+		{Role: wise.RoleAssistant, Content: "```bash\necho TASK_COMPLETE\n```"},
+	}
+	result := wise.Result{
+		Output: "3 files",
+		Reason: wise.ReasonComplete,
+		Steps:  2,
+		Usage:  wise.TokenUsage{TotalTokens: 120},
+	}
+
+	var b bytes.Buffer
+	if err := (markdownFormatter{}).Result(&b, "count the files", messages, result); err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+	got := b.String()
+
+	for _, want := range []string{
+		"# count the files\n",
+		"## Step 1\n\n```bash\nls | wc -l\n```\n",
+		"**Observation**\n\n```\n3\n```\n",
+		"## Step 2\n",
+		"- Reason: complete\n- Steps: 2\n- Tokens: 120\n",
+		"\n3 files\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("markdown missing %q in:\n%s", want, got)
+		}
+	}
+	// The system prompt and the task message aren't steps
+	if strings.Contains(got, "shell agent") || strings.Count(got, "**Observation**") != 1 {
+		t.Errorf("markdown renders messages before the first step:\n%s", got)
+	}
+}
+
+func TestNDJSONFormatter(t *testing.T) {
+	var b bytes.Buffer
+	f := ndjsonFormatter{}
+	events := []wise.Event{
+		{Type: wise.EventStepStarted, Step: 1},
+		{Type: wise.EventCommandStarted, Step: 1, Action: wise.Action{Command: "ls"}},
+		{Type: wise.EventObservation, Step: 1, Output: wise.Output{Stdout: "a\n", ExitCode: 1, Duration: 1500 * time.Millisecond}},
+	}
+	for _, e := range events {
+		if err := f.Event(&b, e); err != nil {
+			t.Fatalf("Event() error = %v", err)
+		}
+	}
+	if err := f.Result(&b, "list", nil, wise.Result{Output: "done", Reason: wise.ReasonComplete, Steps: 1}); err != nil {
+		t.Fatalf("Result() error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines, want one per event plus the result:\n%s", len(lines), b.String())
+	}
+	var decoded []map[string]any
+	for _, line := range lines {
+		var v map[string]any
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("line %q isn't JSON: %v", line, err)
+		}
+		decoded = append(decoded, v)
+	}
+
+	if decoded[1]["command"] != "ls" {
+		t.Errorf("command event = %v, want the command", decoded[1])
+	}
+	if decoded[2]["stdout"] != "a\n" || decoded[2]["exit_code"] != 1.0 || decoded[2]["duration_ms"] != 1500.0 {
+		t.Errorf("observation event = %v, want its output", decoded[2])
+	}
+	if decoded[3]["type"] != "result" || decoded[3]["success"] != true || decoded[3]["response"] != "done" {
+		t.Errorf("result line = %v, want the run result", decoded[3])
+	}
+}
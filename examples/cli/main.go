@@ -1,17 +1,372 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/j0lvera/wise"
 	"github.com/j0lvera/wise/executor/local"
+	"github.com/j0lvera/wise/models"
 	"github.com/j0lvera/wise/models/openai"
 
+	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 )
 
+// Exit codes, one per termination path. A completed task and a command
+// that succeeded without a run share 0 on purpose, so scripts can rely on
+// "wise run ... && next" and the usual zero-means-success convention.
+const (
+	exitSuccess   = 0   // Command succeeded
+	exitComplete  = 0   // Task complete, same as exitSuccess
+	exitFailure   = 1   // Execution failure
+	exitConfig    = 2   // Misuse or bad config
+	exitStepLimit = 3   // Step limit reached
+	exitCostLimit = 4   // Cost limit reached
+	exitTimeout   = 5   // Run timeout reached
+	exitUserAbort = 130 // Interrupted by the user
+)
+
+// configError marks errors caused by bad arguments or configuration.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// exitCode maps an error returned by the command to its exit code.
+func exitCode(err error) int {
+	if err == nil {
+		return exitSuccess
+	}
+
+	var cfgErr *configError
+	if errors.As(err, &cfgErr) {
+		return exitConfig
+	}
+
+	var termErr *wise.TerminatingErr
+	if errors.As(err, &termErr) {
+		switch termErr.Reason {
+		case wise.ReasonComplete:
+			return exitComplete
+		case wise.ReasonStepLimit:
+			return exitStepLimit
+		case wise.ReasonCostLimit:
+			return exitCostLimit
+		case wise.ReasonRunTimeout:
+			return exitTimeout
+		case wise.ReasonUserAbort:
+			return exitUserAbort
+		}
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return exitUserAbort
+	}
+
+	return exitFailure
+}
+
+// promptApproval asks on stderr before each command, reading y/n from in.
+func promptApproval(in *bufio.Reader) wise.ApprovalFunc {
+	return func(ctx context.Context, action wise.Action) (bool, error) {
+		fmt.Fprintf(os.Stderr, "Run this command?\n  $ %s\n[y/N] ", wise.DisplayCommand(action))
+		answer, err := in.ReadString('\n')
+		if err != nil {
+			return false, fmt.Errorf("failed to read answer: %w", err)
+		}
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		return answer == "y" || answer == "yes", nil
+	}
+}
+
+// newAgent builds an agent from the command's flags, logging to logger.
+// in is shared with the approval prompt so it doesn't compete with other
+// stdin readers.
+func newAgent(cmd *cobra.Command, in *bufio.Reader, logger *zerolog.Logger) (wise.Agent, error) {
+	// Build model config — falls back to OPENAI_API_KEY and OPENAI_BASE_URL env vars
+	modelCfg := openai.NewConfig()
+
+	modelName := os.Getenv("MODEL")
+	if modelName == "" {
+		modelName = "anthropic/claude-sonnet-4-5-20250929"
+	}
+
+	model, err := openai.New(modelName, modelCfg)
+	if err != nil {
+		return nil, &configError{fmt.Errorf("failed to create model: %w", err)}
+	}
+
+	// Fail fast on a mistyped model name or a bad key
+	if check, _ := cmd.Flags().GetBool("check-model"); check {
+		if v, ok := model.(models.Validator); ok {
+			ctx, cancel := context.WithTimeout(cmd.Context(), 15*time.Second)
+			err := v.Validate(ctx)
+			cancel()
+			if err != nil {
+				return nil, &configError{fmt.Errorf("model check failed: %w", err)}
+			}
+		}
+	}
+
+	// Build environment config
+	workingDir, _ := cmd.Flags().GetString("working-dir")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+
+	envCfg := local.NewConfig().
+		WithWorkingDir(workingDir).
+		WithTimeout(timeout)
+
+	if stdinData, _ := cmd.Flags().GetBool("stdin-as-data"); stdinData {
+		if interactive, _ := cmd.Flags().GetBool("interactive"); interactive {
+			return nil, &configError{errors.New("--stdin-as-data can't be combined with --interactive")}
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read stdin: %w", err)
+		}
+		envCfg = envCfg.WithStdin(data)
+	}
+
+	env := local.New(envCfg)
+
+	// Build agent config
+	maxSteps, _ := cmd.Flags().GetInt("max-steps")
+	// Keep stdout clean for machine-readable output
+	var output io.Writer = os.Stdout
+	if formatFlag(cmd) != "text" {
+		output = os.Stderr
+	}
+
+	cfg := wise.NewConfig().
+		WithLogger(logger).
+		WithOutput(output).
+		WithMaxSteps(maxSteps).
+		WithGracefulShutdown(true)
+
+	if runTimeout, _ := cmd.Flags().GetDuration("run-timeout"); runTimeout > 0 {
+		cfg = cfg.WithRunTimeout(runTimeout)
+	}
+
+	if showStderr, _ := cmd.Flags().GetBool("show-stderr"); showStderr {
+		cfg = cfg.WithShowStderr(true)
+	}
+
+	if interactive, _ := cmd.Flags().GetBool("interactive"); interactive {
+		cfg = cfg.WithApproval(promptApproval(in))
+	}
+
+	if maxCost, _ := cmd.Flags().GetFloat64("max-cost"); maxCost > 0 {
+		price, err := priceFlag(cmd)
+		if err != nil {
+			return nil, err
+		}
+		cfg = cfg.WithMaxCost(maxCost).
+			WithPricing(map[string]wise.Price{modelName: price})
+	}
+
+	systemPrompt, err := systemPromptFlag(cmd)
+	if err != nil {
+		return nil, err
+	}
+	if systemPrompt != "" {
+		cfg = cfg.WithSystemPrompt(systemPrompt)
+	}
+
+	a, err := wise.New(model, env, cfg)
+	if err != nil {
+		return nil, &configError{fmt.Errorf("failed to create agent: %w", err)}
+	}
+	return a, nil
+}
+
+// RunResult is the --json output of the run command.
+type RunResult struct {
+	Success          bool        `json:"success"`
+	Task             string      `json:"task"`
+	Response         string      `json:"response"`
+	Reason           string      `json:"reason,omitempty"`
+	Error            string      `json:"error,omitempty"`
+	Steps            int         `json:"steps,omitempty"`
+	Tokens           *TokenCount `json:"tokens,omitempty"`
+	Cost             float64     `json:"cost,omitempty"`
+	CostLimitReached bool        `json:"cost_limit_reached,omitempty"`
+}
+
+// TokenCount is the token usage in RunResult.
+type TokenCount struct {
+	Prompt     int `json:"prompt"`
+	Completion int `json:"completion"`
+	Total      int `json:"total"`
+}
+
+// newRunResult summarizes a run for JSON output.
+func newRunResult(task string, r wise.Result) RunResult {
+	result := RunResult{
+		Success:          r.Completed(),
+		Task:             task,
+		Response:         r.Output,
+		Reason:           string(r.Reason),
+		Steps:            r.Steps,
+		Cost:             r.Cost,
+		CostLimitReached: r.Reason == wise.ReasonCostLimit,
+	}
+	if r.Err != nil && !r.Completed() {
+		result.Error = r.Err.Error()
+	}
+	// Models that don't report usage leave the token counts out
+	if r.Usage.TotalTokens > 0 || r.Usage.PromptTokens > 0 || r.Usage.CompletionTokens > 0 {
+		result.Tokens = &TokenCount{
+			Prompt:     r.Usage.PromptTokens,
+			Completion: r.Usage.CompletionTokens,
+			Total:      r.Usage.TotalTokens,
+		}
+	}
+	return result
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// priceFlag parses --price, given as prompt/completion dollars per
+// million tokens, e.g. "3/15".
+func priceFlag(cmd *cobra.Command) (wise.Price, error) {
+	value, _ := cmd.Flags().GetString("price")
+	if value == "" {
+		return wise.Price{}, &configError{errors.New("--max-cost requires --price for the model")}
+	}
+
+	prompt, completion, ok := strings.Cut(value, "/")
+	p, perr := strconv.ParseFloat(prompt, 64)
+	c, cerr := strconv.ParseFloat(completion, 64)
+	if !ok || perr != nil || cerr != nil {
+		return wise.Price{}, &configError{fmt.Errorf("invalid --price %q, expected prompt/completion such as 3/15", value)}
+	}
+	return wise.Price{Prompt: p, Completion: c}, nil
+}
+
+// systemPromptFlag returns the system prompt from --system-prompt, else
+// from --system-prompt-file, else "" for the default prompt.
+func systemPromptFlag(cmd *cobra.Command) (string, error) {
+	if prompt, _ := cmd.Flags().GetString("system-prompt"); prompt != "" {
+		return prompt, nil
+	}
+
+	path, _ := cmd.Flags().GetString("system-prompt-file")
+	if path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", &configError{fmt.Errorf("failed to read system prompt file: %w", err)}
+	}
+	return string(b), nil
+}
+
+// newLogger builds the logger selected by --log-level and --log-file,
+// which newAgent hands to the library with Config.WithLogger. The
+// returned close function releases the log file, if any.
+func newLogger(cmd *cobra.Command) (*zerolog.Logger, func() error, error) {
+	levelName, _ := cmd.Flags().GetString("log-level")
+	level, err := zerolog.ParseLevel(levelName)
+	if err != nil {
+		return nil, nil, &configError{fmt.Errorf("invalid --log-level: %w", err)}
+	}
+
+	var w io.Writer = zerolog.ConsoleWriter{Out: os.Stderr}
+	closeFn := func() error { return nil }
+	if path, _ := cmd.Flags().GetString("log-file"); path != "" {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, &configError{fmt.Errorf("failed to open log file: %w", err)}
+		}
+		w, closeFn = f, f.Close
+	}
+
+	logger := zerolog.New(w).Level(level).With().Timestamp().Logger()
+	return &logger, closeFn, nil
+}
+
+// chat runs a REPL reading prompts from in. Each prompt continues the
+// conversation; /reset, /history and /exit are handled locally.
+func chat(cmd *cobra.Command, in *bufio.Reader) error {
+	ctx := cmd.Context()
+
+	logger, closeLog, err := newLogger(cmd)
+	if err != nil {
+		return err
+	}
+	defer closeLog()
+
+	a, err := newAgent(cmd, in, logger)
+	if err != nil {
+		return err
+	}
+	defer func() { a.Close() }()
+
+	fmt.Fprintln(os.Stderr, "Type a task, or /reset, /history, /exit.")
+	for {
+		fmt.Fprint(os.Stderr, "> ")
+		line, err := in.ReadString('\n')
+		if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+			fmt.Fprintln(os.Stderr)
+			return nil
+		}
+
+		switch prompt := strings.TrimSpace(line); prompt {
+		case "":
+			continue
+		case "/exit":
+			return nil
+		case "/reset":
+			a.Close()
+			if a, err = newAgent(cmd, in, logger); err != nil {
+				return err
+			}
+			fmt.Fprintln(os.Stderr, "Conversation reset.")
+		case "/history":
+			for _, m := range a.Messages() {
+				fmt.Printf("[%s]\n%s\n\n", m.Role, m.Content)
+			}
+		default:
+			if strings.HasPrefix(prompt, "/") {
+				fmt.Fprintf(os.Stderr, "Unknown command %s.\n", prompt)
+				continue
+			}
+
+			output, err := a.Chat(ctx, prompt)
+			if output != "" {
+				fmt.Println(output)
+			}
+			if ctx.Err() != nil {
+				return err
+			}
+
+			var termErr *wise.TerminatingErr
+			if errors.As(err, &termErr) {
+				fmt.Fprintf(os.Stderr, "Stopped: %s.\n", termErr.Reason)
+			} else if err != nil {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+		}
+	}
+}
+
 func main() {
 	rootCmd := &cobra.Command{
 		Use:   "myagent",
@@ -21,56 +376,121 @@ func main() {
 	runCmd := &cobra.Command{
 		Use:   "run [task]",
 		Short: "Run the agent with a task",
-		Args:  cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.ExactArgs(1)(cmd, args); err != nil {
+				return &configError{err}
+			}
+			return nil
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			task := args[0]
-
-			// Build model config — falls back to OPENAI_API_KEY and OPENAI_BASE_URL env vars
-			modelCfg := openai.NewConfig()
+			f, err := formatter(cmd)
+			if err != nil {
+				return err
+			}
 
-			modelName := os.Getenv("MODEL")
-			if modelName == "" {
-				modelName = "anthropic/claude-sonnet-4-5-20250929"
+			logger, closeLog, err := newLogger(cmd)
+			if err != nil {
+				return err
 			}
+			defer closeLog()
 
-			model, err := openai.New(modelName, modelCfg)
+			a, err := newAgent(cmd, bufio.NewReader(os.Stdin), logger)
 			if err != nil {
-				return fmt.Errorf("failed to create model: %w", err)
+				return err
 			}
+			defer a.Close()
 
-			// Build environment config
-			workingDir, _ := cmd.Flags().GetString("working-dir")
-			timeout, _ := cmd.Flags().GetDuration("timeout")
+			if planOnly, _ := cmd.Flags().GetBool("plan-only"); planOnly {
+				plan, err := a.Plan(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(os.Stdout, plan)
+				return nil
+			}
 
-			envCfg := local.NewConfig().
-				WithWorkingDir(workingDir).
-				WithTimeout(timeout)
+			// Stream events to the formatter while the run is in progress
+			events := a.Events()
+			done := make(chan struct{})
+			go func() {
+				defer close(done)
+				for e := range events {
+					_ = f.Event(os.Stdout, e)
+				}
+			}()
 
-			env := local.New(envCfg)
+			task := args[0]
+			if stdinData, _ := cmd.Flags().GetBool("stdin-as-data"); stdinData {
+				task += "\n\nPiped input data is available on the standard input of every command you run."
+			}
 
-			// Build agent config
-			maxSteps, _ := cmd.Flags().GetInt("max-steps")
-			cfg := wise.NewConfig().
-				WithOutput(os.Stdout).
-				WithMaxSteps(maxSteps)
+			_, err = a.Run(cmd.Context(), task)
+			<-done
 
-			a, err := wise.New(model, env, cfg)
-			if err != nil {
-				return fmt.Errorf("failed to create agent: %w", err)
+			var termErr *wise.TerminatingErr
+			if errors.As(err, &termErr) && termErr.Reason == wise.ReasonCostLimit {
+				maxCost, _ := cmd.Flags().GetFloat64("max-cost")
+				fmt.Fprintf(os.Stderr, "Cost limit of $%.2f reached (spent $%.4f).\n", maxCost, a.Cost())
 			}
 
-			_, err = a.Run(cmd.Context(), task)
+			if ferr := f.Result(os.Stdout, args[0], a.Messages(), a.Result()); ferr != nil {
+				return ferr
+			}
 			return err
 		},
 	}
 
-	runCmd.Flags().String("working-dir", ".", "Working directory for commands")
-	runCmd.Flags().Duration("timeout", 30*time.Second, "Command timeout")
-	runCmd.Flags().Int("max-steps", 25, "Maximum number of agent steps")
-
-	rootCmd.AddCommand(runCmd)
+	chatCmd := &cobra.Command{
+		Use:   "chat",
+		Short: "Start an interactive session that keeps history across prompts",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if err := cobra.NoArgs(cmd, args); err != nil {
+				return &configError{err}
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return chat(cmd, bufio.NewReader(os.Stdin))
+		},
+	}
 
-	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+	for _, c := range []*cobra.Command{runCmd, chatCmd} {
+		c.Flags().String("working-dir", ".", "Working directory for commands")
+		c.Flags().Duration("timeout", 30*time.Second, "Command timeout")
+		c.Flags().Int("max-steps", 25, "Maximum number of agent steps per task")
+		c.Flags().Duration("run-timeout", 0, "Stop a task after this long in total, e.g. 10m (0 for no limit)")
+		c.Flags().Bool("interactive", false, "Confirm each command before it runs")
+		c.Flags().Bool("show-stderr", false, "Also print command stderr, prefixed with \"stderr: \"")
+		c.Flags().Bool("check-model", false, "Check the model name and API key with the provider before starting")
+		c.Flags().String("log-level", "disabled", "Log level: trace, debug, info, warn, error or disabled")
+		c.Flags().String("log-file", "", "Write JSON logs to this file instead of stderr")
+		c.Flags().Float64("max-cost", 0, "Stop once the estimated spend reaches this many dollars")
+		c.Flags().String("price", "", "Model price as prompt/completion dollars per million tokens, e.g. 3/15")
+		c.Flags().String("system-prompt", "", "System prompt text (overrides --system-prompt-file)")
+		c.Flags().String("system-prompt-file", "", "Read the system prompt from a file")
 	}
+
+	runCmd.Flags().Bool("plan-only", false, "Print the commands the model would run, without running anything")
+	runCmd.Flags().Bool("stdin-as-data", false, "Pass piped stdin to every command instead of reading it as input")
+	runCmd.Flags().Bool("json", false, "Print the result as JSON (same as --format json)")
+	runCmd.Flags().String("format", "text", "Result format: text, json, markdown or ndjson; agent output goes to stderr unless text")
+
+	rootCmd.AddCommand(runCmd, chatCmd)
+
+	// Cancel the run on Ctrl-C so it exits with the user abort code. The
+	// first Ctrl-C lets the model summarize; a second one stops at once.
+	ctx, cancel := context.WithCancel(context.Background())
+	force, forceStop := context.WithCancel(context.Background())
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt)
+	go func() {
+		<-interrupts
+		cancel()
+		<-interrupts
+		forceStop()
+	}()
+
+	err := rootCmd.ExecuteContext(wise.WithForceStop(ctx, force))
+	signal.Stop(interrupts)
+	os.Exit(exitCode(err))
 }
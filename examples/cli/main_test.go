@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/j0lvera/wise"
+)
+
+func TestExitCode(t *testing.T) {
+	terminated := func(reason wise.TerminationReason) error {
+		return fmt.Errorf("run: %w", &wise.TerminatingErr{Reason: reason})
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"success", nil, exitSuccess},
+		{"complete", terminated(wise.ReasonComplete), exitComplete},
+		{"bad config", &configError{errors.New("unknown model")}, exitConfig},
+		{"step limit", terminated(wise.ReasonStepLimit), exitStepLimit},
+		{"cost limit", terminated(wise.ReasonCostLimit), exitCostLimit},
+		{"run timeout", terminated(wise.ReasonRunTimeout), exitTimeout},
+		{"user abort", terminated(wise.ReasonUserAbort), exitUserAbort},
+		{"cancelled", fmt.Errorf("query: %w", context.Canceled), exitUserAbort},
+		{"failure", errors.New("model unavailable"), exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -40,10 +40,12 @@ func main() {
 		return wise.Output{Stdout: result}, true, nil
 	}
 
-	// Build agent config with custom handler
+	// Build agent config with custom handlers, tried in order. The SQL
+	// tool describes itself, so it is listed in the system prompt.
 	cfg := wise.NewConfig().
 		WithOutput(os.Stdout).
-		WithActionHandler(pythonHandler)
+		WithActionHandlers(pythonHandler).
+		WithDescribedActionHandlers(sqlTool{})
 
 	a, err := wise.New(model, env, cfg)
 	if err != nil {
@@ -65,3 +67,26 @@ func executePythonFunction(cmd string) string {
 	// In a real implementation, you would parse the command and execute Python code
 	return "python function result"
 }
+
+// sqlTool handles SQL queries and describes itself to the model.
+type sqlTool struct{}
+
+func (sqlTool) Name() string { return "sql_query" }
+
+func (sqlTool) Description() string {
+	return "Run a SQL query against the analytics database, e.g. sql_query SELECT count(*) FROM events"
+}
+
+func (sqlTool) HandleAction(ctx context.Context, action wise.Action) (wise.Output, bool, error) {
+	if !strings.HasPrefix(action.Command, "sql_query") {
+		return wise.Output{}, false, nil // Not handled, use default
+	}
+
+	result := executeSQLQuery(action.Command)
+	return wise.Output{Stdout: result}, true, nil
+}
+
+func executeSQLQuery(cmd string) string {
+	// Custom implementation - this is just a placeholder
+	return "sql query result"
+}
@@ -0,0 +1,267 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/executor/local"
+)
+
+// Config holds the environment configuration.
+type Config struct {
+	runtime    string
+	image      string
+	container  string
+	workingDir string
+	volumes    []string
+	timeout    time.Duration
+	validator  executor.CommandValidator
+}
+
+// NewConfig creates a new Config with sensible defaults.
+func NewConfig() Config {
+	return Config{
+		runtime:   "docker",
+		image:     "ubuntu:24.04",
+		timeout:   30 * time.Second,
+		validator: local.NewDefaultValidator(),
+	}
+}
+
+// WithRuntime sets the container CLI binary (e.g. "podman").
+func (c Config) WithRuntime(runtime string) Config {
+	c.runtime = runtime
+	return c
+}
+
+// WithImage sets the image for the container.
+func (c Config) WithImage(image string) Config {
+	c.image = image
+	return c
+}
+
+// WithContainer reuses an existing running container instead of
+// starting one. Reused containers are not removed on Close.
+func (c Config) WithContainer(nameOrID string) Config {
+	c.container = nameOrID
+	return c
+}
+
+// WithWorkingDir sets the working directory inside the container.
+func (c Config) WithWorkingDir(dir string) Config {
+	c.workingDir = dir
+	return c
+}
+
+// WithVolume mounts a host directory into the container.
+func (c Config) WithVolume(hostDir, containerDir string) Config {
+	c.volumes = append(append([]string(nil), c.volumes...), hostDir+":"+containerDir)
+	return c
+}
+
+// WithTimeout sets the command timeout. It is enforced inside the
+// container with timeout(1), which the image must provide, as coreutils
+// and busybox do. Commands get SIGTERM at the timeout and SIGKILL if they
+// are still running killAfter later.
+func (c Config) WithTimeout(d time.Duration) Config {
+	c.timeout = d
+	return c
+}
+
+// WithValidator sets a custom command validator.
+func (c Config) WithValidator(v executor.CommandValidator) Config {
+	c.validator = v
+	return c
+}
+
+// WithoutValidation disables command validation.
+func (c Config) WithoutValidation() Config {
+	c.validator = nil
+	return c
+}
+
+// timeoutGrace is how long the exec client outlives the timeout enforced
+// inside the container, so the container reports the timeout first.
+const timeoutGrace = 5 * time.Second
+
+// killAfter is how long timeout(1) waits after SIGTERM before sending
+// SIGKILL to a command that ignores it.
+const killAfter = 2 * time.Second
+
+// Exit codes of timeout(1) when it fires: 124 if the command stopped on
+// SIGTERM, and 128+9 if it had to be killed, which can't be told apart
+// from a command killed for another reason, e.g. by the OOM killer.
+const (
+	timeoutExitCode = 124
+	killedExitCode  = 128 + 9
+)
+
+// environment implements the ClosableEnvironment interface (unexported).
+type environment struct {
+	cfg       Config
+	mu        sync.Mutex
+	container string
+	owned     bool // container was started by us and is removed on Close
+}
+
+// New creates a new docker environment. The container is started
+// lazily on the first Execute, and removed by Close.
+func New(cfg Config) executor.ClosableEnvironment {
+	// Apply defaults if zero values
+	if cfg.runtime == "" {
+		cfg.runtime = "docker"
+	}
+	if cfg.image == "" {
+		cfg.image = "ubuntu:24.04"
+	}
+	if cfg.timeout == 0 {
+		cfg.timeout = 30 * time.Second
+	}
+	return &environment{cfg: cfg, container: cfg.container}
+}
+
+// Execute runs a bash command in the container via exec.
+func (e *environment) Execute(ctx context.Context, action executor.Action) (executor.Output, error) {
+	if action.Type != executor.ActionTypeBash {
+		return executor.Output{}, executor.UnsupportedAction(action)
+	}
+	if executor.HasInputRefs(action.Command) {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: "#INPUT references are not supported in the docker environment.",
+			Command: action.Command,
+		}
+	}
+
+	// Validate command before execution
+	if e.cfg.validator != nil {
+		if err := e.cfg.validator.Validate(action.Command); err != nil {
+			return executor.Output{}, err
+		}
+	}
+
+	container, err := e.ensureContainer(ctx)
+	if err != nil {
+		return executor.Output{}, err
+	}
+
+	timeout := action.TimeoutOr(e.cfg.timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout+timeoutGrace)
+	defer cancel()
+
+	// Killing the exec client leaves the command running in the
+	// container, so the timeout is enforced inside it. timeout signals the
+	// command's whole process group.
+	seconds := strconv.Itoa(int(math.Ceil(timeout.Seconds())))
+	grace := strconv.Itoa(int(killAfter.Seconds()))
+	args := []string{"exec"}
+	if e.cfg.workingDir != "" {
+		args = append(args, "-w", e.cfg.workingDir)
+	}
+	args = append(args, container, "timeout", "-k", grace, seconds, "bash", "-c", action.Command)
+
+	cmd := exec.CommandContext(timeoutCtx, e.cfg.runtime, args...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	start := time.Now()
+	err = cmd.Run()
+
+	output := executor.Output{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+
+	if err != nil {
+		// docker exec exits with the command's exit code
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			output.ExitCode = exitErr.ExitCode()
+		}
+
+		// Check if it was a timeout, in the container or of the client.
+		// The duration rules out the command's own exit codes: timeout
+		// only exits 124 after the timeout, and only kills after the grace
+		// period that follows it.
+		expired := output.ExitCode == timeoutExitCode && output.Duration >= timeout
+		killed := output.ExitCode == killedExitCode && output.Duration >= timeout+killAfter
+		if expired || killed || errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			output.TimedOut = true
+			output.ExitCode = 0
+			return output, &executor.ExecutionError{
+				Type:    executor.ErrTimeout,
+				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", timeout, output.String()),
+			}
+		}
+
+		return output, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: fmt.Sprintf("Command failed: %s\nOutput:\n%s", err.Error(), output.String()),
+		}
+	}
+
+	return output, nil
+}
+
+// ensureContainer starts the container on first use and returns its ID.
+func (e *environment) ensureContainer(ctx context.Context) (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.container != "" {
+		return e.container, nil
+	}
+
+	args := []string{"run", "-d", "--rm"}
+	if e.cfg.workingDir != "" {
+		args = append(args, "-w", e.cfg.workingDir)
+	}
+	for _, v := range e.cfg.volumes {
+		args = append(args, "-v", v)
+	}
+	// Keep the container alive so commands can be exec'd into it
+	args = append(args, e.cfg.image, "sleep", "infinity")
+
+	out, err := exec.CommandContext(ctx, e.cfg.runtime, args...).Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf("failed to start container: %w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", fmt.Errorf("failed to start container: %w", err)
+	}
+
+	e.container = strings.TrimSpace(string(out))
+	e.owned = true
+	return e.container, nil
+}
+
+// Close removes the container if it was started by this environment.
+func (e *environment) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if !e.owned {
+		return nil
+	}
+
+	out, err := exec.Command(e.cfg.runtime, "rm", "-f", e.container).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to remove container %s: %w: %s", e.container, err, strings.TrimSpace(string(out)))
+	}
+
+	e.container = ""
+	e.owned = false
+	return nil
+}
@@ -0,0 +1,104 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+// requireDocker skips the test unless a docker daemon is reachable.
+func requireDocker(t *testing.T) {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		t.Skip("docker not installed")
+	}
+	if err := exec.Command("docker", "info").Run(); err != nil {
+		t.Skip("docker daemon not reachable")
+	}
+}
+
+func TestExecuteTimeoutKillsCommandInContainer(t *testing.T) {
+	requireDocker(t)
+
+	env := New(NewConfig().WithTimeout(time.Second))
+	t.Cleanup(func() { env.Close() })
+	ctx := context.Background()
+
+	output, err := env.Execute(ctx, executor.Action{Type: executor.ActionTypeBash, Command: "sleep 1234 | cat"})
+	var execErr *executor.ExecutionError
+	if !errors.As(err, &execErr) || execErr.Type != executor.ErrTimeout {
+		t.Fatalf("Execute() error = %v, want timeout", err)
+	}
+	if !output.TimedOut {
+		t.Error("output.TimedOut = false, want true")
+	}
+
+	// List the command lines of every process left in the container
+	list := `for p in /proc/[0-9]*; do tr '\0' ' ' < "$p/cmdline"; echo; done`
+	output, err = env.Execute(ctx, executor.Action{Type: executor.ActionTypeBash, Command: list})
+	if err != nil {
+		t.Fatalf("listing processes: %v", err)
+	}
+	if strings.Contains(output.Stdout, "sleep 1234") {
+		t.Errorf("command still running after timeout:\n%s", output.Stdout)
+	}
+}
+
+// fakeRuntime writes a docker stand-in that runs exec'd commands on the
+// host, for testing how their exit is reported without a daemon.
+func fakeRuntime(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("timeout"); err != nil {
+		t.Skip("timeout not installed")
+	}
+	script := `#!/bin/sh
+case "$1" in
+run) echo fake ;;
+exec) shift; [ "$1" = "-w" ] && shift 2; shift; "$@"; exit $? ;;
+esac
+`
+	path := filepath.Join(t.TempDir(), "docker")
+	if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestExecuteTimeoutDetection(t *testing.T) {
+	env := New(NewConfig().WithRuntime(fakeRuntime(t)).WithTimeout(time.Second).WithoutValidation())
+	t.Cleanup(func() { env.Close() })
+
+	tests := []struct {
+		name     string
+		command  string
+		timedOut bool
+		exitCode int
+	}{
+		{"stops on SIGTERM", "sleep 30", true, 0},
+		{"ignores SIGTERM", "trap '' TERM; sleep 30", true, 0},
+		{"killed before the timeout", "kill -KILL $$", false, 137},
+		{"exits 124 itself", "exit 124", false, 124},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := env.Execute(context.Background(), executor.Action{Type: executor.ActionTypeBash, Command: tt.command})
+			var execErr *executor.ExecutionError
+			if !errors.As(err, &execErr) {
+				t.Fatalf("Execute() error = %v, want an ExecutionError", err)
+			}
+			if output.TimedOut != tt.timedOut || (execErr.Type == executor.ErrTimeout) != tt.timedOut {
+				t.Errorf("TimedOut = %v, error type %v, want timed out %v", output.TimedOut, execErr.Type, tt.timedOut)
+			}
+			if output.ExitCode != tt.exitCode {
+				t.Errorf("ExitCode = %d, want %d", output.ExitCode, tt.exitCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package echo provides an environment that runs nothing, for tests and
+// demos of the agent loop.
+package echo
+
+import (
+	"context"
+	"fmt"
+	"maps"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+// Config holds the environment configuration.
+type Config struct {
+	responses map[string]executor.Output
+}
+
+// NewConfig creates a new Config with no scripted responses.
+func NewConfig() Config {
+	return Config{}
+}
+
+// WithResponse scripts the output returned for an exact command.
+func (c Config) WithResponse(command string, output executor.Output) Config {
+	return c.WithResponses(map[string]executor.Output{command: output})
+}
+
+// WithResponses scripts outputs for several commands at once.
+func (c Config) WithResponses(responses map[string]executor.Output) Config {
+	merged := maps.Clone(c.responses)
+	if merged == nil {
+		merged = map[string]executor.Output{}
+	}
+	maps.Copy(merged, responses)
+	c.responses = merged
+	return c
+}
+
+// environment implements the Environment interface (unexported).
+type environment struct {
+	cfg Config
+}
+
+// New creates an environment that returns the scripted output for known
+// commands and echoes any other command back as its stdout.
+func New(cfg Config) executor.Environment {
+	return &environment{cfg: cfg}
+}
+
+// Execute returns the action's scripted output without running it.
+func (e *environment) Execute(ctx context.Context, action executor.Action) (executor.Output, error) {
+	if err := ctx.Err(); err != nil {
+		return executor.Output{}, fmt.Errorf("command cancelled: %w", err)
+	}
+	if action.Type != executor.ActionTypeBash {
+		return executor.Output{}, executor.UnsupportedAction(action)
+	}
+
+	if output, ok := e.cfg.responses[action.Command]; ok {
+		return output, nil
+	}
+	return executor.Output{Stdout: action.Command}, nil
+}
@@ -1,22 +1,52 @@
 package executor
 
-import "context"
+import (
+	"context"
+	"fmt"
+	"time"
+)
 
-// Action represents a command to execute.
+// Action types.
+const (
+	ActionTypeBash      = "bash"
+	ActionTypeWriteFile = "write_file"
+	ActionTypeWait      = "wait"
+)
+
+// Action represents a command to execute. A non-zero Timeout overrides
+// the environment's configured command timeout for this action. Inputs
+// holds out-of-band content the command may reference as #INPUT:name.
+// For write_file actions, Command holds the content to write to Path. For
+// wait actions, which the agent handles itself, Command holds a duration
+// such as "30s".
 type Action struct {
 	Type    string
 	Command string
+	Path    string
+	Timeout time.Duration
+	Inputs  map[string][]byte
 }
 
-// Output represents command execution results.
+// TimeoutOr returns the action's timeout, or def if it has none.
+func (a Action) TimeoutOr(def time.Duration) time.Duration {
+	if a.Timeout > 0 {
+		return a.Timeout
+	}
+	return def
+}
+
+// Output represents command execution results. Duration is how long the
+// command ran, zero if the environment doesn't measure it.
 type Output struct {
 	Stdout   string
 	Stderr   string
 	ExitCode int
 	TimedOut bool
+	Duration time.Duration
 }
 
-// String returns a combined string of stdout and stderr.
+// String returns a combined string of stdout and stderr. The other
+// fields are left out so they don't reach the model unless asked for.
 func (o Output) String() string {
 	if o.Stderr != "" {
 		return o.Stdout + "\n" + o.Stderr
@@ -33,3 +63,56 @@ type Environment interface {
 type CommandValidator interface {
 	Validate(command string) error
 }
+
+// ClosableEnvironment is an Environment holding resources that must be
+// released with Close when the caller is done.
+type ClosableEnvironment interface {
+	Environment
+	Close() error
+}
+
+// Setupper is implemented by environments that prepare resources before
+// the first command. Execute runs the setup on demand; calling Setup
+// explicitly surfaces its errors before the task starts.
+type Setupper interface {
+	Setup(ctx context.Context) error
+}
+
+// WorkingDirReporter is implemented by environments that can report the
+// directory commands currently run in.
+type WorkingDirReporter interface {
+	WorkingDir(ctx context.Context) (string, error)
+}
+
+// ExecutionErrorType indicates the type of execution error.
+type ExecutionErrorType string
+
+const (
+	ErrTimeout   ExecutionErrorType = "timeout"
+	ErrExecution ExecutionErrorType = "execution"
+	ErrBlocked   ExecutionErrorType = "blocked"
+)
+
+// ExecutionError represents an error during command execution.
+// For blocked commands, Command holds the rejected command and Pattern
+// the blocklist pattern it matched, if any.
+type ExecutionError struct {
+	Type    ExecutionErrorType
+	Message string
+	Command string
+	Pattern string
+}
+
+func (e *ExecutionError) Error() string {
+	return fmt.Sprintf("execution error [%s]: %s", e.Type, e.Message)
+}
+
+// UnsupportedAction returns the error for an action type an environment
+// can't run. It is an ExecutionError, so the agent reports it to the
+// model, which can fall back to a bash command, instead of ending the run.
+func UnsupportedAction(action Action) error {
+	return &ExecutionError{
+		Type:    ErrExecution,
+		Message: fmt.Sprintf("%s actions are not supported in this environment. Use a bash command instead.", action.Type),
+	}
+}
@@ -0,0 +1,61 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// inputRef matches a reference to out-of-band content, e.g. #INPUT:data.csv.
+var inputRef = regexp.MustCompile(`#INPUT:([A-Za-z0-9_.-]+)`)
+
+// HasInputRefs reports whether command references any #INPUT:name content.
+func HasInputRefs(command string) bool {
+	return inputRef.MatchString(command)
+}
+
+// ExpandInputs writes each input referenced in command to a file in dir
+// and replaces every #INPUT:name reference with the file's quoted path.
+// Referencing an input that doesn't exist is an execution error.
+func ExpandInputs(command string, inputs map[string][]byte, dir string) (string, error) {
+	paths := make(map[string]string)
+	for _, m := range inputRef.FindAllStringSubmatch(command, -1) {
+		name := m[1]
+		if _, ok := paths[name]; ok {
+			continue
+		}
+		data, ok := inputs[name]
+		if !ok || !filepath.IsLocal(name) || name == "." {
+			return "", &ExecutionError{
+				Type:    ErrExecution,
+				Message: fmt.Sprintf("Unknown input %q. Available inputs: %s.", name, inputNames(inputs)),
+				Command: command,
+			}
+		}
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			return "", fmt.Errorf("failed to write input %q: %w", name, err)
+		}
+		paths[name] = "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+	}
+
+	return inputRef.ReplaceAllStringFunc(command, func(ref string) string {
+		return paths[strings.TrimPrefix(ref, "#INPUT:")]
+	}), nil
+}
+
+// inputNames lists the input names for error messages.
+func inputNames(inputs map[string][]byte) string {
+	if len(inputs) == 0 {
+		return "none"
+	}
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
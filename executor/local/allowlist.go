@@ -0,0 +1,100 @@
+package local
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// AllowlistValidator only permits commands whose executables are in a
+// configured set. Every command in a pipeline or list is checked, and
+// command substitution is rejected since it can't be inspected.
+type AllowlistValidator struct {
+	allowed map[string]bool
+}
+
+// NewAllowlistValidator creates a validator permitting the given executables.
+func NewAllowlistValidator(executables ...string) *AllowlistValidator {
+	allowed := make(map[string]bool, len(executables))
+	for _, e := range executables {
+		allowed[e] = true
+	}
+	return &AllowlistValidator{allowed: allowed}
+}
+
+// Validate checks that every executable in the command is allowed.
+func (v *AllowlistValidator) Validate(command string) error {
+	commands, err := splitCommands(command)
+	if err != nil {
+		return &ExecutionError{
+			Type:    ErrBlocked,
+			Message: fmt.Sprintf("Command blocked: %s. Please run allowed commands directly.", err),
+			Command: command,
+		}
+	}
+
+	for _, words := range commands {
+		executable := leadingExecutable(words)
+		if executable == "" {
+			continue
+		}
+		if !v.allowed[executable] {
+			return &ExecutionError{
+				Type:    ErrBlocked,
+				Message: fmt.Sprintf("Command blocked: %q is not an allowed command. Allowed commands: %s.", executable, v.list()),
+				Command: command,
+			}
+		}
+	}
+	return nil
+}
+
+// list returns the allowed executables as a comma-separated string.
+func (v *AllowlistValidator) list() string {
+	names := make([]string, 0, len(v.allowed))
+	for name := range v.allowed {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return strings.Join(names, ", ")
+}
+
+// reservedWords are shell keywords that may start a simple command as
+// split by splitCommands, followed by the command they introduce.
+var reservedWords = map[string]bool{
+	"!": true, "{": true, "}": true, "if": true, "then": true, "else": true,
+	"elif": true, "fi": true, "do": true, "done": true, "while": true,
+	"until": true, "esac": true, "time": true,
+}
+
+// clauseWords start compound commands whose remaining words aren't
+// commands, like the list of a for loop.
+var clauseWords = map[string]bool{
+	"for": true, "select": true, "case": true,
+}
+
+// leadingExecutable returns the first word that isn't a variable
+// assignment, a redirection or a reserved word. Clauses like "for x in
+// a b" have none.
+func leadingExecutable(words []string) string {
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+		if clauseWords[w] {
+			return ""
+		}
+		if reservedWords[w] {
+			continue
+		}
+		if _, target, ok := redirection(w); ok {
+			// The target is the next word in "> file"
+			if target == "" {
+				i++
+			}
+			continue
+		}
+		if !isAssignment(w) {
+			return w
+		}
+	}
+	return ""
+}
@@ -0,0 +1,123 @@
+package local
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAllowlistValidatorRedirections(t *testing.T) {
+	v := NewAllowlistValidator("ls", "echo", "cat")
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"ls 2>&1", true},
+		{"echo hi >&2", true},
+		{"ls &>log", true},
+		{"ls &>>log", true},
+		{"cat <&3", true},
+		{"echo hi >|out", true},
+		{"ls 2>&1 | cat", true},
+		{">out echo hi", true},
+		{"> out echo hi", true},
+		{"ls & rm -rf x", false},
+		{"ls && rm x", false},
+		{"ls 2>&1; rm x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := v.Validate(tt.command)
+			if tt.allowed && err != nil {
+				t.Errorf("Validate(%q) = %v, want allowed", tt.command, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("Validate(%q) = nil, want blocked", tt.command)
+			}
+		})
+	}
+}
+
+func TestSplitCommandsRedirections(t *testing.T) {
+	tests := []struct {
+		command string
+		want    [][]string
+	}{
+		{"ls 2>&1", [][]string{{"ls", "2>&1"}}},
+		{"echo hi >&2", [][]string{{"echo", "hi", ">&2"}}},
+		{"cmd &>log", [][]string{{"cmd", "&>log"}}},
+		{"a & b", [][]string{{"a"}, {"b"}}},
+		{"a|b", [][]string{{"a"}, {"b"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := splitCommands(tt.command)
+		if err != nil {
+			t.Fatalf("splitCommands(%q): %v", tt.command, err)
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("splitCommands(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
+
+func TestAllowlistValidatorCompoundCommands(t *testing.T) {
+	v := NewAllowlistValidator("ls", "echo", "cat", "test", "grep")
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"cat <<EOF\nrm -rf / is just text\nEOF", true},
+		{"cat <<'EOF'\n$(rm -rf /)\nEOF\nls", true},
+		{"cat <<-EOF\n\tnot a command\n\tEOF\necho done", true},
+		{"cat <<EOF\n$(rm -rf /)\nEOF", false},
+		{"cat <<EOF\ntext\nEOF\nrm x", false},
+		{"cat <<A <<B\nfirst\nA\nsecond\nB\nls", true},
+		{"cat <<<hello", true},
+		{"if test -f x; then cat x; else echo none; fi", true},
+		{"if test -f x; then rm x; fi", false},
+		{"for f in a b; do echo $f; done", true},
+		{"for f in a b; do rm $f; done", false},
+		{"while true; do ls; done", false},
+		{"! grep -q x file && { echo missing; }", true},
+		{"case $1 in\n  start) echo up ;;\n  stop|halt) echo down ;;\nesac", true},
+		{"case $1 in start) rm x ;; esac", false},
+		{"((n = 1 << 2))\nrm x", false},
+		{"((n = 1 << 2)); echo ok", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := v.Validate(tt.command)
+			if tt.allowed && err != nil {
+				t.Errorf("Validate(%q) = %v, want allowed", tt.command, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("Validate(%q) = nil, want blocked", tt.command)
+			}
+		})
+	}
+}
+
+func TestSplitCommandsSkipsHeredocsAndCasePatterns(t *testing.T) {
+	tests := []struct {
+		command string
+		want    [][]string
+	}{
+		{"cat <<EOF >out\nbody\nEOF\nls", [][]string{{"cat", "<<EOF", ">out"}, {"ls"}}},
+		{"cat << 'E F'\nbody\nE F", [][]string{{"cat", "<<", "E F"}}},
+		{"case $x in (a|b) ls;; *) pwd;; esac", [][]string{{"case", "$x", "in"}, {"ls"}, {"pwd"}, {"esac"}}},
+	}
+
+	for _, tt := range tests {
+		got, err := splitCommands(tt.command)
+		if err != nil {
+			t.Fatalf("splitCommands(%q): %v", tt.command, err)
+		}
+		if !slices.EqualFunc(got, tt.want, slices.Equal) {
+			t.Errorf("splitCommands(%q) = %q, want %q", tt.command, got, tt.want)
+		}
+	}
+}
@@ -5,20 +5,49 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"maps"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/j0lvera/wise/executor"
 )
 
-// ActionType for bash commands.
-const ActionTypeBash = "bash"
+// Action types handled by the local environment.
+const (
+	ActionTypeBash      = executor.ActionTypeBash
+	ActionTypeWriteFile = executor.ActionTypeWriteFile
+)
+
+// waitDelay bounds how long a command's output is read after it exits
+// or is cancelled, e.g. while a background process keeps it open.
+const waitDelay = 2 * time.Second
+
+// orphanNote tells the model that background processes were stopped.
+const orphanNote = "[background processes still running after the command exited were killed; run long-lived processes with their output redirected, e.g. cmd >log 2>&1 &]\n"
+
+// DefaultMaxCommandBytes is the default limit on the length of a command.
+const DefaultMaxCommandBytes = 64 << 10
 
 // Config holds the environment configuration.
 type Config struct {
-	timeout    time.Duration
-	workingDir string
-	validator  executor.CommandValidator
+	timeout         time.Duration
+	workingDir      string
+	validator       executor.CommandValidator
+	persistentShell bool
+	shell           []string
+	createDir       bool
+	env             map[string]string
+	noInheritEnv    bool
+	stdin           []byte
+	preload         map[string]string
+	maxCommandBytes int
+	wrap            func(command string) string
 }
 
 // NewConfig creates a new Config with sensible defaults.
@@ -53,24 +82,163 @@ func (c Config) WithoutValidation() Config {
 	return c
 }
 
+// WithPersistentShell runs all commands in a single long-lived bash
+// process, so cd, exported variables and functions persist across steps.
+// A timed out command is interrupted; the session is only restarted if
+// the command doesn't stop.
+func (c Config) WithPersistentShell(enabled bool) Config {
+	c.persistentShell = enabled
+	return c
+}
+
+// WithCreateWorkingDir creates the working directory, including missing
+// parents, on first use instead of requiring it to exist.
+func (c Config) WithCreateWorkingDir(enabled bool) Config {
+	c.createDir = enabled
+	return c
+}
+
+// WithEnv sets environment variables for executed commands, overriding
+// inherited ones of the same name.
+func (c Config) WithEnv(env map[string]string) Config {
+	c.env = maps.Clone(env)
+	return c
+}
+
+// WithInheritEnv controls whether commands inherit this process's
+// environment (the default). Without it, commands only see the variables
+// set with WithEnv, plus a minimal PATH unless WithEnv sets one.
+func (c Config) WithInheritEnv(enabled bool) Config {
+	c.noInheritEnv = !enabled
+	return c
+}
+
+// WithStdin feeds data to the standard input of every command, e.g. to
+// let commands process piped input. The persistent shell ignores it.
+func (c Config) WithStdin(data []byte) Config {
+	c.stdin = data
+	return c
+}
+
+// WithPreloadFiles writes files, keyed by path relative to the working
+// directory, before the first command runs. Existing files are
+// overwritten; paths outside the working directory are rejected.
+func (c Config) WithPreloadFiles(files map[string]string) Config {
+	c.preload = maps.Clone(files)
+	return c
+}
+
+// WithMaxCommandBytes rejects commands longer than n bytes, which are
+// almost always a runaway heredoc, and write_file content over the same
+// limit. Defaults to DefaultMaxCommandBytes; a negative n disables the
+// limit.
+func (c Config) WithMaxCommandBytes(n int) Config {
+	c.maxCommandBytes = n
+	return c
+}
+
+// WithCommandWrapper transforms each command before it runs, e.g. to run
+// it under nice, firejail or cgexec for lightweight resource limits.
+// Commands are validated first, so the wrapper can't hide them from the
+// validator's patterns: validate original, then wrap, then execute.
+//
+// The wrapped command still runs through the shell. With a persistent
+// shell, wrappers that start a new process, such as
+// "nice bash -c '...'", don't keep cd or variable changes.
+func (c Config) WithCommandWrapper(wrap func(command string) string) Config {
+	c.wrap = wrap
+	return c
+}
+
+// WithShell sets the program and leading arguments used to run each
+// command, e.g. WithShell("cmd", "/c"). The command is passed as the final
+// argument. Defaults to bash -c, or powershell -Command on Windows. The
+// persistent shell always uses bash.
+func (c Config) WithShell(shell string, args ...string) Config {
+	c.shell = append([]string{shell}, args...)
+	return c
+}
+
+// minimalPath is the PATH given to commands that don't inherit one.
+const minimalPath = "/usr/local/bin:/usr/bin:/bin"
+
+// commandEnv returns the environment for executed commands, or nil to
+// inherit this process's environment unchanged.
+func (c Config) commandEnv() []string {
+	if !c.noInheritEnv && len(c.env) == 0 {
+		return nil
+	}
+
+	var env []string
+	if c.noInheritEnv {
+		if _, ok := c.env["PATH"]; !ok {
+			env = append(env, "PATH="+minimalPath)
+		}
+	} else {
+		env = os.Environ()
+	}
+	// Later entries win, so explicit variables override inherited ones
+	for _, k := range slices.Sorted(maps.Keys(c.env)) {
+		env = append(env, k+"="+c.env[k])
+	}
+	return env
+}
+
+// defaultShell returns the shell for the current platform.
+func defaultShell() []string {
+	if runtime.GOOS == "windows" {
+		return []string{"powershell", "-NoProfile", "-NonInteractive", "-Command"}
+	}
+	return []string{"bash", "-c"}
+}
+
 // environment implements the Environment interface (unexported).
 type environment struct {
-	cfg Config
+	cfg      Config
+	mu       sync.Mutex
+	shell    *shell
+	setupMu  sync.Mutex
+	setupRun bool
 }
 
 // New creates a new local environment.
-func New(cfg Config) executor.Environment {
+func New(cfg Config) executor.ClosableEnvironment {
 	// Apply defaults if zero values
 	if cfg.timeout == 0 {
 		cfg.timeout = 30 * time.Second
 	}
+	if len(cfg.shell) == 0 {
+		cfg.shell = defaultShell()
+	}
+	if cfg.maxCommandBytes == 0 {
+		cfg.maxCommandBytes = DefaultMaxCommandBytes
+	}
+	// Path scope defaults to the working directory
+	root := cfg.workingDir
+	if root == "" {
+		root = "."
+	}
+	cfg.validator = scopeToRoot(cfg.validator, root)
 	return &environment{cfg: cfg}
 }
 
-// Execute runs a bash command and returns the output.
+// Execute runs a bash command, or writes a file for write_file actions,
+// and returns the output.
 func (e *environment) Execute(ctx context.Context, action executor.Action) (executor.Output, error) {
-	if action.Type != ActionTypeBash {
-		return executor.Output{}, fmt.Errorf("unsupported action type: %s", action.Type)
+	switch action.Type {
+	case ActionTypeBash:
+	case ActionTypeWriteFile:
+		return e.writeFile(ctx, action)
+	default:
+		return executor.Output{}, executor.UnsupportedAction(action)
+	}
+
+	if n := len(action.Command); e.cfg.maxCommandBytes > 0 && n > e.cfg.maxCommandBytes {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrBlocked,
+			Message: fmt.Sprintf("Command is %d bytes, over the %d byte limit. Split it into smaller commands or write large content in steps.", n, e.cfg.maxCommandBytes),
+			Command: action.Command,
+		}
 	}
 
 	// Validate command before execution
@@ -80,24 +248,77 @@ func (e *environment) Execute(ctx context.Context, action executor.Action) (exec
 		}
 	}
 
-	timeoutCtx, cancel := context.WithTimeout(ctx, e.cfg.timeout)
+	if err := e.Setup(ctx); err != nil {
+		return executor.Output{}, err
+	}
+
+	// Inputs are materialized as temporary files for this command only
+	if executor.HasInputRefs(action.Command) {
+		dir, err := os.MkdirTemp("", "wise-inputs-")
+		if err != nil {
+			return executor.Output{}, fmt.Errorf("failed to create input directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		if action.Command, err = executor.ExpandInputs(action.Command, action.Inputs, dir); err != nil {
+			return executor.Output{}, err
+		}
+	}
+
+	if e.cfg.wrap != nil {
+		action.Command = e.cfg.wrap(action.Command)
+	}
+
+	if e.cfg.persistentShell {
+		return e.executeInShell(ctx, action)
+	}
+
+	timeout := action.TimeoutOr(e.cfg.timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	cmd := exec.CommandContext(timeoutCtx, "bash", "-c", action.Command)
+	args := append(slices.Clone(e.cfg.shell[1:]), action.Command)
+	cmd := exec.CommandContext(timeoutCtx, e.cfg.shell[0], args...)
 
 	if e.cfg.workingDir != "" {
 		cmd.Dir = e.cfg.workingDir
 	}
+	cmd.Env = e.cfg.commandEnv()
+	// Without configured input, stdin is /dev/null so interactive
+	// programs see EOF rather than waiting until the timeout
+	if e.cfg.stdin != nil {
+		cmd.Stdin = bytes.NewReader(e.cfg.stdin)
+	}
+	// Cancellation kills the whole process group, so children such as
+	// the rest of a pipeline can't keep the output open
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		killGroup(cmd)
+		return nil
+	}
+	cmd.WaitDelay = waitDelay
 
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
 
+	start := time.Now()
 	err := cmd.Run()
+	// A background process still held the output after the command
+	// exited. The command itself succeeded; stop what it left running,
+	// which would otherwise outlive the environment unnoticed.
+	orphaned := errors.Is(err, exec.ErrWaitDelay)
+	if orphaned {
+		killGroup(cmd)
+		err = nil
+	}
 
 	output := executor.Output{
-		Stdout: stdout.String(),
-		Stderr: stderr.String(),
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+	if orphaned {
+		output.Stderr += orphanNote
 	}
 
 	if err != nil {
@@ -106,7 +327,7 @@ func (e *environment) Execute(ctx context.Context, action executor.Action) (exec
 			output.TimedOut = true
 			return output, &ExecutionError{
 				Type:    ErrTimeout,
-				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", e.cfg.timeout, output.String()),
+				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", timeout, output.String()),
 			}
 		}
 
@@ -124,21 +345,242 @@ func (e *environment) Execute(ctx context.Context, action executor.Action) (exec
 	return output, nil
 }
 
-// ExecutionErrorType indicates the type of execution error.
-type ExecutionErrorType string
+// Setup creates the working directory if configured to and writes the
+// preloaded files. It runs once; a failed attempt is retried on the next
+// call.
+func (e *environment) Setup(ctx context.Context) error {
+	e.setupMu.Lock()
+	defer e.setupMu.Unlock()
 
-const (
-	ErrTimeout   ExecutionErrorType = "timeout"
-	ErrExecution ExecutionErrorType = "execution"
-	ErrBlocked   ExecutionErrorType = "blocked"
-)
+	if e.setupRun {
+		return nil
+	}
+	if e.cfg.createDir && e.cfg.workingDir != "" {
+		if err := os.MkdirAll(e.cfg.workingDir, 0o755); err != nil {
+			return fmt.Errorf("failed to create working directory %s: %w", e.cfg.workingDir, err)
+		}
+	}
+	if err := e.preloadFiles(); err != nil {
+		return err
+	}
+	e.setupRun = true
+	return nil
+}
 
-// ExecutionError represents an error during command execution.
-type ExecutionError struct {
-	Type    ExecutionErrorType
-	Message string
+// preloadFiles writes the configured files into the working directory.
+func (e *environment) preloadFiles() error {
+	root := e.cfg.workingDir
+	if root == "" {
+		root = "."
+	}
+	for _, name := range slices.Sorted(maps.Keys(e.cfg.preload)) {
+		if !filepath.IsLocal(name) {
+			return fmt.Errorf("failed to preload %s: path is outside the working directory", name)
+		}
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("failed to preload %s: %w", name, err)
+		}
+		if err := os.WriteFile(path, []byte(e.cfg.preload[name]), 0o644); err != nil {
+			return fmt.Errorf("failed to preload %s: %w", name, err)
+		}
+	}
+	return nil
 }
 
-func (e *ExecutionError) Error() string {
-	return fmt.Sprintf("execution error [%s]: %s", e.Type, e.Message)
+// writeFile writes the action's content to its path, which must be
+// inside the working directory even after resolving symlinks. The write is
+// validated as the equivalent cat > path command, with path made absolute,
+// and the content is held to the command size limit. Missing parent
+// directories are created.
+func (e *environment) writeFile(ctx context.Context, action executor.Action) (executor.Output, error) {
+	if n := len(action.Command); e.cfg.maxCommandBytes > 0 && n > e.cfg.maxCommandBytes {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrBlocked,
+			Message: fmt.Sprintf("Content for %s is %d bytes, over the %d byte limit. Write the file in smaller parts.", action.Path, n, e.cfg.maxCommandBytes),
+			Command: action.Path,
+		}
+	}
+
+	root := absPath(e.cfg.workingDir)
+	path := action.Path
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+
+	if e.cfg.validator != nil {
+		if err := e.cfg.validator.Validate("cat > " + shellQuote(path)); err != nil {
+			return executor.Output{}, err
+		}
+	}
+	if !within(root, path) {
+		return executor.Output{}, writeBlocked(action.Path, "path is outside the working directory")
+	}
+	// A symlink inside the root may still point outside it
+	resolvedRoot, err := resolveSymlinks(root)
+	if err != nil {
+		resolvedRoot = root
+	}
+	if resolved, err := resolveSymlinks(path); err != nil || !within(resolvedRoot, resolved) {
+		return executor.Output{}, writeBlocked(action.Path, "path resolves outside the working directory")
+	}
+
+	if err := e.Setup(ctx); err != nil {
+		return executor.Output{}, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: fmt.Sprintf("Cannot write %s: %v", action.Path, err),
+		}
+	}
+	if err := os.WriteFile(path, []byte(action.Command), 0o644); err != nil {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: fmt.Sprintf("Cannot write %s: %v", action.Path, err),
+		}
+	}
+	return executor.Output{
+		Stdout: fmt.Sprintf("Wrote %d bytes to %s", len(action.Command), action.Path),
+	}, nil
 }
+
+// writeBlocked returns the error for a refused write.
+func writeBlocked(path, reason string) error {
+	return &executor.ExecutionError{
+		Type:    executor.ErrBlocked,
+		Message: fmt.Sprintf("Cannot write %s: %s.", path, reason),
+		Command: path,
+	}
+}
+
+// resolveSymlinks resolves symlinks in the longest existing prefix of the
+// absolute path p, keeping the missing remainder. A dangling symlink is an
+// error, since writing through it would create its target.
+func resolveSymlinks(p string) (string, error) {
+	var rest []string
+	for dir := p; ; dir = filepath.Dir(dir) {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err == nil {
+			return filepath.Join(append([]string{resolved}, rest...)...), nil
+		}
+		if _, lerr := os.Lstat(dir); lerr == nil {
+			return "", err
+		}
+		if filepath.Dir(dir) == dir {
+			return "", err
+		}
+		rest = append([]string{filepath.Base(dir)}, rest...)
+	}
+}
+
+// WorkingDir returns the absolute directory commands run in. For the
+// persistent shell this follows cd commands.
+func (e *environment) WorkingDir(ctx context.Context) (string, error) {
+	if !e.cfg.persistentShell {
+		return absPath(e.cfg.workingDir), nil
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shell == nil || !e.shell.alive() {
+		return absPath(e.cfg.workingDir), nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	stdout, _, exitCode, err := e.shell.run(ctx, "pwd")
+	if err != nil {
+		return "", err
+	}
+	if exitCode != 0 {
+		return "", fmt.Errorf("pwd exited with code %d", exitCode)
+	}
+	return strings.TrimSpace(stdout), nil
+}
+
+// executeInShell runs a command in the persistent shell, starting it
+// on first use or after it exited.
+func (e *environment) executeInShell(ctx context.Context, action executor.Action) (executor.Output, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shell == nil || !e.shell.alive() {
+		sh, err := startShell(e.cfg.workingDir, e.cfg.commandEnv())
+		if err != nil {
+			return executor.Output{}, err
+		}
+		e.shell = sh
+	}
+
+	timeout := action.TimeoutOr(e.cfg.timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	stdout, stderr, exitCode, err := e.shell.run(timeoutCtx, action.Command)
+
+	output := executor.Output{
+		Stdout:   stdout,
+		Stderr:   stderr,
+		Duration: time.Since(start),
+	}
+
+	if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+		output.TimedOut = true
+		msg := fmt.Sprintf("Command timed out after %s. Partial output:\n%s", timeout, output.String())
+		if !e.shell.alive() {
+			msg += "\nThe shell session was killed; working directory and variables will be reset."
+		}
+		return output, &ExecutionError{
+			Type:    ErrTimeout,
+			Message: msg,
+		}
+	}
+
+	if errors.Is(err, errShellExited) {
+		return output, &ExecutionError{
+			Type:    ErrExecution,
+			Message: fmt.Sprintf("Shell exited while running the command; the session will be restarted and its state reset.\nOutput:\n%s", output.String()),
+		}
+	}
+	if err != nil {
+		return output, err
+	}
+
+	if exitCode != 0 {
+		output.ExitCode = exitCode
+		return output, &ExecutionError{
+			Type:    ErrExecution,
+			Message: fmt.Sprintf("Command failed: exit status %d\nOutput:\n%s", exitCode, output.String()),
+		}
+	}
+
+	return output, nil
+}
+
+// Close ends the persistent shell session, if any.
+func (e *environment) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.shell == nil {
+		return nil
+	}
+	err := e.shell.close()
+	e.shell = nil
+	return err
+}
+
+// Aliases for the shared execution error types.
+type (
+	ExecutionError     = executor.ExecutionError
+	ExecutionErrorType = executor.ExecutionErrorType
+)
+
+const (
+	ErrTimeout   = executor.ErrTimeout
+	ErrExecution = executor.ErrExecution
+	ErrBlocked   = executor.ErrBlocked
+)
@@ -0,0 +1,179 @@
+package local
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+// requireUnix skips tests relying on bash and process groups.
+func requireUnix(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+}
+
+func TestExecuteStdinIsEmpty(t *testing.T) {
+	requireUnix(t)
+
+	env := New(NewConfig().WithTimeout(10 * time.Second))
+	start := time.Now()
+	output, err := env.Execute(context.Background(), executor.Action{
+		Type:    ActionTypeBash,
+		Command: `read x; echo "status=$? x=$x"`,
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("read took %s, want an immediate EOF", elapsed)
+	}
+	if got := strings.TrimSpace(output.Stdout); got != "status=1 x=" {
+		t.Errorf("stdout = %q, want %q", got, "status=1 x=")
+	}
+}
+
+func TestExecuteTimeoutKillsPipeline(t *testing.T) {
+	requireUnix(t)
+
+	env := New(NewConfig().WithTimeout(500 * time.Millisecond))
+	start := time.Now()
+	output, err := env.Execute(context.Background(), executor.Action{
+		Type:    ActionTypeBash,
+		Command: "sleep 100 | cat",
+	})
+	if err == nil || !output.TimedOut {
+		t.Fatalf("Execute() = %+v, %v; want a timeout", output, err)
+	}
+	if elapsed := time.Since(start); elapsed > 3*time.Second {
+		t.Errorf("timed out command returned after %s", elapsed)
+	}
+}
+
+func TestExecuteBackgroundChildDoesNotBlock(t *testing.T) {
+	requireUnix(t)
+
+	env := New(NewConfig().WithTimeout(30 * time.Second))
+	start := time.Now()
+	output, err := env.Execute(context.Background(), executor.Action{
+		Type:    ActionTypeBash,
+		Command: "sleep 30 & echo $!",
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > waitDelay+2*time.Second {
+		t.Errorf("command returned after %s, want about %s", elapsed, waitDelay)
+	}
+	if !strings.Contains(output.Stderr, orphanNote) {
+		t.Errorf("stderr = %q, want a note that the background process was killed", output.Stderr)
+	}
+
+	// The background child is killed rather than left running
+	stat := filepath.Join("/proc", strings.TrimSpace(output.Stdout), "stat")
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		return
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		b, err := os.ReadFile(stat)
+		// Gone, or a zombie waiting for init to reap it
+		if err != nil || strings.Contains(string(b), ") Z ") {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("background process still running: %s", b)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestExecuteHeredocWritesFile(t *testing.T) {
+	requireUnix(t)
+
+	dir := t.TempDir()
+	env := New(NewConfig().WithWorkingDir(dir))
+	output, err := env.Execute(context.Background(), executor.Action{
+		Type:    ActionTypeBash,
+		Command: "cat > notes.txt <<'EOF'\nfirst $HOME\nsecond\nEOF\ncat notes.txt",
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if want := "first $HOME\nsecond\n"; output.Stdout != want {
+		t.Errorf("stdout = %q, want %q", output.Stdout, want)
+	}
+}
+
+func TestExecuteWriteFileRefusesBlockedPaths(t *testing.T) {
+	requireUnix(t)
+
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "new.txt"), filepath.Join(dir, "dangling")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  Config
+		path string
+	}{
+		{"outside the working dir", NewConfig(), "../x.txt"},
+		{"symlinked dir pointing outside", NewConfig(), "escape/x.txt"},
+		{"dangling symlink pointing outside", NewConfig(), "dangling"},
+		{"not in the allowlist", NewConfig().WithValidator(NewAllowlistValidator("ls")), "x.txt"},
+		{"outside a narrower path scope", NewConfig().WithValidator(NewPathScopeValidator(filepath.Join(dir, "src"))), "x.txt"},
+		{"over the size limit", NewConfig().WithMaxCommandBytes(4), "x.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := New(tt.cfg.WithWorkingDir(dir))
+			_, err := env.Execute(context.Background(), executor.Action{
+				Type:    ActionTypeWriteFile,
+				Path:    tt.path,
+				Command: "content\n",
+			})
+			var execErr *executor.ExecutionError
+			if !errors.As(err, &execErr) || execErr.Type != executor.ErrBlocked {
+				t.Fatalf("Execute() error = %v, want a blocked ExecutionError", err)
+			}
+		})
+	}
+
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Errorf("files written outside the working dir: %v", entries)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "x.txt")); !os.IsNotExist(err) {
+		t.Errorf("blocked write created x.txt: %v", err)
+	}
+}
+
+func TestExecuteWriteFileCreatesParents(t *testing.T) {
+	dir := t.TempDir()
+	env := New(NewConfig().WithWorkingDir(dir))
+	_, err := env.Execute(context.Background(), executor.Action{
+		Type:    ActionTypeWriteFile,
+		Path:    "src/new/main.go",
+		Command: "package main\n",
+	})
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "src/new/main.go"))
+	if err != nil || string(got) != "package main\n" {
+		t.Errorf("file = %q, %v, want the content written", got, err)
+	}
+}
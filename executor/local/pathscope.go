@@ -0,0 +1,249 @@
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+// writeCommands are executables whose path arguments are modified.
+var writeCommands = map[string]bool{
+	"rm": true, "rmdir": true, "mv": true, "cp": true, "touch": true,
+	"mkdir": true, "tee": true, "ln": true, "chmod": true, "chown": true,
+	"truncate": true, "install": true, "dd": true,
+}
+
+// copyCommands are write commands that only modify their destination:
+// the last operand, or the directory given with -t.
+var copyCommands = map[string]bool{"cp": true, "ln": true, "install": true}
+
+// alwaysAllowedPaths are special files commands commonly write to.
+var alwaysAllowedPaths = map[string]bool{
+	"/dev/null": true, "/dev/stdout": true, "/dev/stderr": true,
+}
+
+// PathScopeValidator blocks commands that write outside a root directory:
+// redirection targets, path arguments of file-modifying commands (rm, mv,
+// touch, tee, ...) and the destination of cp, ln and install, so copying
+// in from outside is allowed. Relative paths resolve against the root.
+// This is a best-effort check that raises the bar, not a sandbox.
+type PathScopeValidator struct {
+	root  string
+	reads bool
+}
+
+// NewPathScopeValidator creates a validator confining writes to root.
+// An empty root defaults to the environment's working directory when the
+// validator is set via Config.WithValidator.
+func NewPathScopeValidator(root string) *PathScopeValidator {
+	v := &PathScopeValidator{}
+	if root != "" {
+		v.root = absPath(root)
+	}
+	return v
+}
+
+// WithReads also confines paths read by any command (e.g. cat /etc/passwd).
+func (v *PathScopeValidator) WithReads(enabled bool) *PathScopeValidator {
+	c := *v
+	c.reads = enabled
+	return &c
+}
+
+// withRoot returns a copy confined to root.
+func (v *PathScopeValidator) withRoot(root string) *PathScopeValidator {
+	c := *v
+	c.root = absPath(root)
+	return &c
+}
+
+// scopeToRoot confines path scope validators without a root, including
+// those inside a chain or WarnOnly, to root.
+func scopeToRoot(v executor.CommandValidator, root string) executor.CommandValidator {
+	return executor.MapValidators(v, func(v executor.CommandValidator) executor.CommandValidator {
+		if ps, ok := v.(*PathScopeValidator); ok && ps.root == "" {
+			return ps.withRoot(root)
+		}
+		return v
+	})
+}
+
+// Validate checks that the command doesn't target paths outside the root.
+func (v *PathScopeValidator) Validate(command string) error {
+	root := v.root
+	if root == "" {
+		root = absPath(".")
+	}
+
+	commands, err := splitCommands(command)
+	if err != nil {
+		return &ExecutionError{
+			Type:    ErrBlocked,
+			Message: fmt.Sprintf("Command blocked: %s. Please use paths inside %s directly.", err, root),
+			Command: command,
+		}
+	}
+
+	for _, words := range commands {
+		for _, p := range v.targets(words) {
+			if !within(root, p) {
+				return &ExecutionError{
+					Type:    ErrBlocked,
+					Message: fmt.Sprintf("Command blocked: path %q is outside the allowed directory %s.", p, root),
+					Command: command,
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// targets returns the paths a simple command writes to, or all path-like
+// words when reads are confined too.
+func (v *PathScopeValidator) targets(words []string) []string {
+	var paths, operands []string
+	var dest string
+	makeDirs := false
+	executable := leadingExecutable(words)
+	execIdx := slices.Index(words, executable)
+
+	for i := 0; i < len(words); i++ {
+		w := words[i]
+
+		// Redirections: >file, > file, 2>>file, &>file, <file
+		if op, rest, ok := redirection(w); ok {
+			if op == "<" && !v.reads {
+				continue
+			}
+			if rest == "" && i+1 < len(words) {
+				i++
+				rest = words[i]
+			}
+			if rest != "" && !strings.HasPrefix(rest, "&") {
+				paths = append(paths, rest)
+			}
+			continue
+		}
+
+		// Skip leading assignments, the executable itself and flags
+		if i <= execIdx {
+			continue
+		}
+		if strings.HasPrefix(w, "-") {
+			if !copyCommands[executable] {
+				continue
+			}
+			if dir, next, ok := targetDirFlag(w); ok {
+				if next && i+1 < len(words) {
+					i++
+					dir = words[i]
+				}
+				dest = dir
+			} else if executable == "install" && (w == "--directory" || !strings.HasPrefix(w, "--") && strings.Contains(w, "d")) {
+				// install -d creates every operand as a directory
+				makeDirs = true
+			}
+			continue
+		}
+
+		switch {
+		case executable == "dd":
+			if p, ok := strings.CutPrefix(w, "of="); ok {
+				paths = append(paths, p)
+			} else if p, ok := strings.CutPrefix(w, "if="); ok && v.reads {
+				paths = append(paths, p)
+			}
+		case copyCommands[executable]:
+			operands = append(operands, w)
+		case writeCommands[executable]:
+			paths = append(paths, w)
+		case v.reads && isPathLike(w):
+			paths = append(paths, w)
+		}
+	}
+
+	switch {
+	case v.reads || makeDirs:
+		paths = append(paths, operands...)
+	case dest == "" && len(operands) > 1:
+		// A lone operand, as in ln -s /usr/bin/tool, targets the working directory
+		dest = operands[len(operands)-1]
+	}
+	if dest != "" {
+		paths = append(paths, dest)
+	}
+	return paths
+}
+
+// targetDirFlag reports whether w sets the destination directory, as in
+// -t dir, -tdir or --target-directory=dir. next is true when the
+// directory is the following word.
+func targetDirFlag(w string) (dir string, next, ok bool) {
+	if dir, ok := strings.CutPrefix(w, "--target-directory"); ok {
+		if dir == "" {
+			return "", true, true
+		}
+		if dir, ok := strings.CutPrefix(dir, "="); ok {
+			return dir, false, true
+		}
+		return "", false, false
+	}
+	if strings.HasPrefix(w, "--") {
+		return "", false, false
+	}
+	_, dir, ok = strings.Cut(w[1:], "t")
+	return dir, ok && dir == "", ok
+}
+
+// redirection splits a redirection word into its operator and target.
+func redirection(w string) (op, target string, ok bool) {
+	s := strings.TrimLeft(w, "0123456789&")
+	switch {
+	case strings.HasPrefix(s, ">>"):
+		return ">>", s[2:], true
+	case strings.HasPrefix(s, ">"):
+		return ">", s[1:], true
+	case strings.HasPrefix(s, "<"):
+		return "<", s[1:], true
+	}
+	return "", "", false
+}
+
+// isPathLike reports whether w looks like a filesystem path.
+func isPathLike(w string) bool {
+	return strings.HasPrefix(w, "/") || strings.HasPrefix(w, "~") ||
+		w == ".." || strings.HasPrefix(w, "../") || strings.Contains(w, "/../")
+}
+
+// within reports whether p, resolved against root, stays inside root.
+func within(root, p string) bool {
+	if strings.HasPrefix(p, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return false
+		}
+		p = home + p[1:]
+	}
+	if !filepath.IsAbs(p) {
+		p = filepath.Join(root, p)
+	}
+	p = filepath.Clean(p)
+
+	if alwaysAllowedPaths[p] {
+		return true
+	}
+	rel, err := filepath.Rel(root, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// absPath returns an absolute, cleaned form of p.
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
@@ -0,0 +1,78 @@
+package local
+
+import (
+	"testing"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/rs/zerolog"
+)
+
+func TestPathScopeValidatorCopyDestinations(t *testing.T) {
+	root := t.TempDir()
+	v := NewPathScopeValidator(root)
+
+	tests := []struct {
+		command string
+		allowed bool
+	}{
+		{"cp /etc/hosts hosts", true},
+		{"cp -r /usr/share/doc/foo ./docs", true},
+		{"cp /etc/hosts /etc/hosts.bak", false},
+		{"cp notes.txt /tmp/notes.txt", false},
+		{"cp -t docs /etc/hosts /etc/passwd", true},
+		{"cp -tdocs /etc/hosts", true},
+		{"cp --target-directory=docs /etc/hosts", true},
+		{"cp --target-directory docs /etc/hosts", true},
+		{"cp -t /tmp notes.txt", false},
+		{"cp -vt /tmp notes.txt", false},
+		{"ln -s /usr/bin/python3 python", true},
+		{"ln -s /usr/bin/python3", true},
+		{"ln -s python /usr/local/bin/python", false},
+		{"install -m 755 /usr/bin/tool bin/tool", true},
+		{"install -m 755 tool /usr/local/bin/tool", false},
+		{"install -d build dist", true},
+		{"install -d build /opt/dist", false},
+		{"mv /tmp/file here", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.command, func(t *testing.T) {
+			err := v.Validate(tt.command)
+			if tt.allowed && err != nil {
+				t.Errorf("Validate(%q) = %v, want allowed", tt.command, err)
+			}
+			if !tt.allowed && err == nil {
+				t.Errorf("Validate(%q) = nil, want blocked", tt.command)
+			}
+		})
+	}
+}
+
+func TestPathScopeValidatorReadsChecksCopySources(t *testing.T) {
+	v := NewPathScopeValidator(t.TempDir()).WithReads(true)
+
+	if err := v.Validate("cp /etc/hosts hosts"); err == nil {
+		t.Error("Validate(cp /etc/hosts hosts) = nil, want the source blocked")
+	}
+}
+
+func TestScopeToRootUnwrapsWarnOnly(t *testing.T) {
+	root := t.TempDir()
+	nop := zerolog.Nop()
+	v := scopeToRoot(executor.WarnOnly(executor.ChainValidator(NewPathScopeValidator("")), &nop), root)
+
+	var scoped *PathScopeValidator
+	executor.MapValidators(v, func(inner executor.CommandValidator) executor.CommandValidator {
+		scoped, _ = inner.(*PathScopeValidator)
+		return inner
+	})
+	if scoped == nil {
+		t.Fatal("path scope validator lost its WarnOnly or chain wrapping")
+	}
+	if scoped.root != root {
+		t.Errorf("root = %q, want %q", scoped.root, root)
+	}
+	if err := v.Validate("rm -rf /etc"); err != nil {
+		t.Errorf("Validate() = %v, want WarnOnly to allow it", err)
+	}
+}
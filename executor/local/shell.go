@@ -0,0 +1,207 @@
+package local
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// interruptGrace is how long a timed out command gets to exit after
+// being interrupted before the whole shell is killed.
+const interruptGrace = 2 * time.Second
+
+// errShellExited is returned when the shell process exits mid-command.
+var errShellExited = errors.New("shell exited")
+
+// shell is a long-lived bash process that runs commands sequentially,
+// so working directory, variables and functions persist between them.
+type shell struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout chan string
+	stderr chan string
+	marker string
+	done   chan struct{}
+	dead   bool
+}
+
+// startShell starts bash in dir, reading commands from stdin. A nil env
+// inherits this process's environment.
+func startShell(dir string, env []string) (*shell, error) {
+	cmd := exec.Command("bash", "--noprofile", "--norc")
+	cmd.Dir = dir
+	cmd.Env = env
+	setProcessGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shell stdin: %w", err)
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shell stdout: %w", err)
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open shell stderr: %w", err)
+	}
+
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, fmt.Errorf("failed to generate marker: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start shell: %w", err)
+	}
+
+	s := &shell{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: make(chan string, 64),
+		stderr: make(chan string, 64),
+		marker: "__WISE_DONE_" + hex.EncodeToString(b),
+		done:   make(chan struct{}),
+	}
+	go readLines(stdout, s.stdout)
+	go readLines(stderr, s.stderr)
+	go func() {
+		_ = cmd.Wait()
+		close(s.done)
+	}()
+
+	// A trap (rather than ignoring INT) keeps the shell alive on interrupt
+	// while children still get the default INT behavior
+	if _, err := io.WriteString(stdin, "trap : INT\n"); err != nil {
+		s.kill()
+		return nil, fmt.Errorf("failed to initialize shell: %w", err)
+	}
+
+	return s, nil
+}
+
+// readLines sends each line read from r to ch, closing ch at EOF.
+func readLines(r io.Reader, ch chan<- string) {
+	br := bufio.NewReader(r)
+	for {
+		line, err := br.ReadString('\n')
+		if line != "" {
+			ch <- line
+		}
+		if err != nil {
+			close(ch)
+			return
+		}
+	}
+}
+
+// run executes a command and waits for its completion marker. On timeout
+// the command is interrupted; if it doesn't stop, the shell is killed and
+// alive reports false.
+func (s *shell) run(ctx context.Context, command string) (stdout, stderr string, exitCode int, err error) {
+	// eval keeps syntax errors from desynchronizing the session, and
+	// stdin is detached so commands can't consume the control stream
+	script := fmt.Sprintf("eval %s < /dev/null\nprintf '\\n%s %%d\\n' $?\nprintf '\\n%s\\n' >&2\n",
+		shellQuote(command), s.marker, s.marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		s.dead = true
+		return "", "", 0, errShellExited
+	}
+
+	var outBuf, errBuf strings.Builder
+	outDone, errDone := false, false
+	exitCode = -1
+
+	var grace <-chan time.Time
+	cancelled := ctx.Done()
+
+	for !outDone || !errDone {
+		select {
+		case line, ok := <-s.stdout:
+			if !ok {
+				s.dead = true
+				return trimMarkerNewline(outBuf.String()), trimMarkerNewline(errBuf.String()), exitCode, errShellExited
+			}
+			if rest, found := strings.CutPrefix(line, s.marker+" "); found {
+				exitCode, _ = strconv.Atoi(strings.TrimSpace(rest))
+				outDone = true
+				continue
+			}
+			outBuf.WriteString(line)
+		case line, ok := <-s.stderr:
+			if !ok {
+				s.dead = true
+				return trimMarkerNewline(outBuf.String()), trimMarkerNewline(errBuf.String()), exitCode, errShellExited
+			}
+			if strings.TrimSpace(line) == s.marker {
+				errDone = true
+				continue
+			}
+			errBuf.WriteString(line)
+		case <-cancelled:
+			cancelled = nil
+			if err := interruptGroup(s.cmd); err != nil {
+				s.kill()
+			}
+			grace = time.After(interruptGrace)
+		case <-grace:
+			s.kill()
+			grace = nil
+		}
+	}
+
+	return trimMarkerNewline(outBuf.String()), trimMarkerNewline(errBuf.String()), exitCode, ctx.Err()
+}
+
+// alive reports whether the shell process is still running.
+func (s *shell) alive() bool {
+	if s.dead {
+		return false
+	}
+	select {
+	case <-s.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// kill terminates the shell and all its children.
+func (s *shell) kill() {
+	s.dead = true
+	killGroup(s.cmd)
+	s.stdin.Close()
+}
+
+// close ends the shell session.
+func (s *shell) close() error {
+	if !s.alive() {
+		return nil
+	}
+	s.stdin.Close()
+	select {
+	case <-s.done:
+	case <-time.After(interruptGrace):
+		killGroup(s.cmd)
+		<-s.done
+	}
+	return nil
+}
+
+// trimMarkerNewline removes the newline printed before the marker.
+func trimMarkerNewline(s string) string {
+	return strings.TrimSuffix(s, "\n")
+}
+
+// shellQuote quotes s for safe use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,21 @@
+//go:build !unix
+
+package local
+
+import (
+	"errors"
+	"os/exec"
+)
+
+// setProcessGroup is a no-op on platforms without process groups.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// interruptGroup is unsupported; the caller falls back to killing the shell.
+func interruptGroup(cmd *exec.Cmd) error {
+	return errors.New("interrupt not supported on this platform")
+}
+
+// killGroup kills the shell process.
+func killGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
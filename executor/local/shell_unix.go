@@ -0,0 +1,26 @@
+//go:build unix
+
+package local
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs the shell in its own session and process group so
+// its children can be signalled together without affecting the caller.
+// Without a controlling terminal, programs that open /dev/tty (vim, less,
+// ssh password prompts) fail immediately instead of waiting for input.
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
+
+// interruptGroup sends SIGINT to the shell's process group.
+func interruptGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+}
+
+// killGroup sends SIGKILL to the shell's process group.
+func killGroup(cmd *exec.Cmd) {
+	_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
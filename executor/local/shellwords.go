@@ -0,0 +1,248 @@
+package local
+
+import (
+	"errors"
+	"strings"
+)
+
+// errSubstitution is returned for commands using command substitution,
+// whose effect can't be inspected statically.
+var errSubstitution = errors.New("command substitution is not allowed")
+
+// heredoc is a here-document whose body starts after the current line.
+type heredoc struct {
+	delimiter string
+	stripTabs bool // <<- strips leading tabs from the body and delimiter
+	literal   bool // A quoted delimiter disables expansion in the body
+}
+
+// splitCommands splits a shell command line into simple commands on
+// unquoted ;, &, |, newlines and parentheses, returning the words of
+// each. The redirection operators >&, <&, &> and >| stay in their words.
+// Quotes are removed from words. Here-document bodies are skipped, as
+// are the patterns of case clauses. This is a best-effort tokenizer, not
+// a full shell parser.
+func splitCommands(command string) ([][]string, error) {
+	var (
+		commands [][]string
+		words    []string
+		word     strings.Builder
+		inWord   bool
+		quote    byte
+		heredocs []heredoc
+		// A case pattern, ended by ), follows "case word in" and ;;
+		casePattern bool
+	)
+
+	endWord := func() {
+		if inWord {
+			words = append(words, word.String())
+			word.Reset()
+			inWord = false
+		}
+	}
+	endCommand := func() {
+		endWord()
+		if len(words) > 0 {
+			casePattern = words[0] == "case" && words[len(words)-1] == "in"
+			commands = append(commands, words)
+			words = nil
+		}
+	}
+
+	for i := 0; i < len(command); i++ {
+		c := command[i]
+
+		switch quote {
+		case '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteByte(c)
+			}
+			continue
+		case '"':
+			switch {
+			case c == '"':
+				quote = 0
+			case c == '`' || (c == '$' && i+1 < len(command) && command[i+1] == '('):
+				return nil, errSubstitution
+			case c == '\\' && i+1 < len(command):
+				i++
+				word.WriteByte(command[i])
+			default:
+				word.WriteByte(c)
+			}
+			continue
+		}
+
+		switch c {
+		case '\'', '"':
+			quote = c
+			inWord = true
+		case '\\':
+			if i+1 < len(command) {
+				i++
+				word.WriteByte(command[i])
+				inWord = true
+			}
+		case '`':
+			return nil, errSubstitution
+		case '$':
+			if i+1 < len(command) && command[i+1] == '(' {
+				return nil, errSubstitution
+			}
+			word.WriteByte(c)
+			inWord = true
+		case ' ', '\t':
+			endWord()
+		case '&', '|':
+			// Part of a redirection like 2>&1, <&3, &>log or >|file
+			afterRedirect := inWord && (command[i-1] == '>' || (c == '&' && command[i-1] == '<'))
+			if afterRedirect || (c == '&' && i+1 < len(command) && command[i+1] == '>') {
+				word.WriteByte(c)
+				inWord = true
+				continue
+			}
+			if c == '|' && casePattern {
+				// Alternatives of a case pattern, like a|b)
+				endWord()
+				words = nil
+				continue
+			}
+			endCommand()
+		case '<':
+			heredocOp := strings.HasPrefix(command[i:], "<<") && !strings.HasPrefix(command[i:], "<<<")
+			if heredocOp && (i == 0 || command[i-1] != '<') {
+				heredocs = append(heredocs, readHeredoc(command[i+2:]))
+			}
+			word.WriteByte(c)
+			inWord = true
+		case ';':
+			endCommand()
+			if i+1 < len(command) && (command[i+1] == ';' || command[i+1] == '&') {
+				// ;;, ;& or ;;& ends a case item and a pattern follows
+				i++
+				if command[i] == ';' && i+1 < len(command) && command[i+1] == '&' {
+					i++
+				}
+				casePattern = true
+			}
+		case ')':
+			if casePattern {
+				endWord()
+				words = nil
+				casePattern = false
+				continue
+			}
+			endCommand()
+		case '\n':
+			endCommand()
+			for _, h := range heredocs {
+				end, err := skipHeredoc(command, i+1, h)
+				if err != nil {
+					return nil, err
+				}
+				i = end
+			}
+			heredocs = nil
+		case '(':
+			endCommand()
+			if strings.HasPrefix(command[i:], "((") {
+				// Arithmetic, where << is a shift rather than a heredoc
+				end := strings.Index(command[i:], "))")
+				if end < 0 {
+					end = len(command) - i
+				}
+				arith := command[i : i+end]
+				if strings.Contains(arith, "$(") || strings.Contains(arith, "`") {
+					return nil, errSubstitution
+				}
+				i += end + 1
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	endCommand()
+
+	return commands, nil
+}
+
+// readHeredoc reads the delimiter following a << operator.
+func readHeredoc(s string) heredoc {
+	var h heredoc
+	if strings.HasPrefix(s, "-") {
+		h.stripTabs = true
+		s = s[1:]
+	}
+	s = strings.TrimLeft(s, " \t")
+
+	var delimiter strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+			delimiter.WriteByte(c)
+		case c == '\'' || c == '"':
+			quote = c
+			h.literal = true
+		case c == '\\' && i+1 < len(s):
+			i++
+			delimiter.WriteByte(s[i])
+			h.literal = true
+		case strings.IndexByte(" \t\n;&|()<>", c) >= 0:
+			h.delimiter = delimiter.String()
+			return h
+		default:
+			delimiter.WriteByte(c)
+		}
+	}
+	h.delimiter = delimiter.String()
+	return h
+}
+
+// skipHeredoc skips the body of h starting at start, returning the index
+// of the newline ending its delimiter line. The body of a heredoc with an
+// unquoted delimiter is expanded, so command substitution in it is
+// rejected like on the command line.
+func skipHeredoc(command string, start int, h heredoc) (int, error) {
+	for start < len(command) {
+		end := strings.IndexByte(command[start:], '\n')
+		if end < 0 {
+			end = len(command)
+		} else {
+			end += start
+		}
+		line := command[start:end]
+		if h.stripTabs {
+			line = strings.TrimLeft(line, "\t")
+		}
+		if line == h.delimiter {
+			return end, nil
+		}
+		if !h.literal && (strings.Contains(line, "$(") || strings.Contains(line, "`")) {
+			return 0, errSubstitution
+		}
+		start = end + 1
+	}
+	return len(command), nil
+}
+
+// isAssignment reports whether word is a variable assignment like FOO=bar.
+func isAssignment(word string) bool {
+	name, _, found := strings.Cut(word, "=")
+	if !found || name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
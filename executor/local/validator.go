@@ -3,6 +3,7 @@ package local
 import (
 	"fmt"
 	"regexp"
+	"slices"
 
 	"github.com/j0lvera/wise/executor"
 )
@@ -55,6 +56,12 @@ func NewDefaultValidator() executor.CommandValidator {
 	return v
 }
 
+// NewBlocklistValidatorExtending creates a validator with the default
+// dangerous patterns plus the given ones.
+func NewBlocklistValidatorExtending(extra ...string) (*BlocklistValidator, error) {
+	return NewBlocklistValidator(slices.Concat(DefaultBlockedPatterns, extra))
+}
+
 // Validate checks if the command matches any blocked pattern.
 func (v *BlocklistValidator) Validate(command string) error {
 	for _, re := range v.patterns {
@@ -62,6 +69,8 @@ func (v *BlocklistValidator) Validate(command string) error {
 			return &ExecutionError{
 				Type:    ErrBlocked,
 				Message: fmt.Sprintf("Command blocked for safety: matches pattern %q. Please use a safer alternative.", re.String()),
+				Command: command,
+				Pattern: re.String(),
 			}
 		}
 	}
@@ -0,0 +1,313 @@
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/executor/local"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Config holds the environment configuration.
+type Config struct {
+	host           string
+	user           string
+	keyFile        string
+	knownHostsFile string
+	insecure       bool
+	workingDir     string
+	timeout        time.Duration
+	validator      executor.CommandValidator
+}
+
+// NewConfig creates a new Config with sensible defaults.
+func NewConfig() Config {
+	return Config{
+		timeout:   30 * time.Second,
+		validator: local.NewDefaultValidator(),
+	}
+}
+
+// WithHost sets the remote host, as host or host:port (default port 22).
+func (c Config) WithHost(host string) Config {
+	c.host = host
+	return c
+}
+
+// WithUser sets the remote user.
+func (c Config) WithUser(user string) Config {
+	c.user = user
+	return c
+}
+
+// WithKeyFile sets the private key used for authentication.
+func (c Config) WithKeyFile(path string) Config {
+	c.keyFile = path
+	return c
+}
+
+// WithKnownHostsFile sets the known_hosts file used to verify the host key.
+// Defaults to ~/.ssh/known_hosts.
+func (c Config) WithKnownHostsFile(path string) Config {
+	c.knownHostsFile = path
+	return c
+}
+
+// WithInsecureIgnoreHostKey skips host key verification (use with caution).
+func (c Config) WithInsecureIgnoreHostKey() Config {
+	c.insecure = true
+	return c
+}
+
+// WithWorkingDir sets the remote working directory for commands.
+func (c Config) WithWorkingDir(dir string) Config {
+	c.workingDir = dir
+	return c
+}
+
+// WithTimeout sets the command timeout.
+func (c Config) WithTimeout(d time.Duration) Config {
+	c.timeout = d
+	return c
+}
+
+// WithValidator sets a custom command validator.
+func (c Config) WithValidator(v executor.CommandValidator) Config {
+	c.validator = v
+	return c
+}
+
+// WithoutValidation disables command validation.
+func (c Config) WithoutValidation() Config {
+	c.validator = nil
+	return c
+}
+
+// environment implements the ClosableEnvironment interface (unexported).
+type environment struct {
+	cfg       Config
+	addr      string
+	clientCfg *ssh.ClientConfig
+	mu        sync.Mutex
+	client    *ssh.Client
+}
+
+// New creates a new SSH environment. The connection is opened on the
+// first Execute and reused across commands until Close.
+func New(cfg Config) (executor.ClosableEnvironment, error) {
+	if cfg.host == "" {
+		return nil, fmt.Errorf("host is required (set via WithHost)")
+	}
+	if cfg.user == "" {
+		return nil, fmt.Errorf("user is required (set via WithUser)")
+	}
+	if cfg.keyFile == "" {
+		return nil, fmt.Errorf("key file is required (set via WithKeyFile)")
+	}
+	if cfg.timeout == 0 {
+		cfg.timeout = 30 * time.Second
+	}
+
+	key, err := os.ReadFile(cfg.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file: %w", err)
+	}
+
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+	if !cfg.insecure {
+		path := cfg.knownHostsFile
+		if path == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+			}
+			path = filepath.Join(home, ".ssh", "known_hosts")
+		}
+		hostKeyCallback, err = knownhosts.New(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+		}
+	}
+
+	addr := cfg.host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	return &environment{
+		cfg:  cfg,
+		addr: addr,
+		clientCfg: &ssh.ClientConfig{
+			User:            cfg.user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+		},
+	}, nil
+}
+
+// Execute runs a bash command on the remote host in a fresh session.
+func (e *environment) Execute(ctx context.Context, action executor.Action) (executor.Output, error) {
+	if action.Type != executor.ActionTypeBash {
+		return executor.Output{}, executor.UnsupportedAction(action)
+	}
+	if executor.HasInputRefs(action.Command) {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: "#INPUT references are not supported in the ssh environment.",
+			Command: action.Command,
+		}
+	}
+
+	// Validate command before execution
+	if e.cfg.validator != nil {
+		if err := e.cfg.validator.Validate(action.Command); err != nil {
+			return executor.Output{}, err
+		}
+	}
+
+	session, err := e.newSession(ctx)
+	if err != nil {
+		return executor.Output{}, err
+	}
+	defer session.Close()
+
+	var stdout, stderr bytes.Buffer
+	session.Stdout = &stdout
+	session.Stderr = &stderr
+
+	command := "bash -c " + shellQuote(action.Command)
+	if e.cfg.workingDir != "" {
+		command = "cd " + shellQuote(e.cfg.workingDir) + " && " + command
+	}
+
+	timeout := action.TimeoutOr(e.cfg.timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	if err := session.Start(command); err != nil {
+		return executor.Output{}, fmt.Errorf("failed to start command: %w", err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- session.Wait() }()
+
+	// Closing the session on cancellation unblocks Wait
+	select {
+	case err = <-done:
+	case <-timeoutCtx.Done():
+		_ = session.Signal(ssh.SIGKILL)
+		session.Close()
+		err = <-done
+	}
+
+	output := executor.Output{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+
+	if err != nil {
+		// Check if it was a timeout
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			output.TimedOut = true
+			return output, &executor.ExecutionError{
+				Type:    executor.ErrTimeout,
+				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", timeout, output.String()),
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return output, fmt.Errorf("command cancelled: %w", ctxErr)
+		}
+
+		// Get remote exit code if available
+		var exitErr *ssh.ExitError
+		if errors.As(err, &exitErr) {
+			output.ExitCode = exitErr.ExitStatus()
+		}
+
+		return output, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: fmt.Sprintf("Command failed: %s\nOutput:\n%s", err.Error(), output.String()),
+		}
+	}
+
+	return output, nil
+}
+
+// newSession opens a session on the shared client, dialing on first use.
+// A broken connection is dropped so the next call redials.
+func (e *environment) newSession(ctx context.Context) (*ssh.Session, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client == nil {
+		client, err := e.dial(ctx)
+		if err != nil {
+			return nil, err
+		}
+		e.client = client
+	}
+
+	session, err := e.client.NewSession()
+	if err != nil {
+		e.client.Close()
+		e.client = nil
+		return nil, fmt.Errorf("failed to open session: %w", err)
+	}
+	return session, nil
+}
+
+// dial connects to the remote host, honoring ctx during the handshake.
+func (e *environment) dial(ctx context.Context) (*ssh.Client, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", e.addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", e.addr, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	c, chans, reqs, err := ssh.NewClientConn(conn, e.addr, e.clientCfg)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh handshake with %s failed: %w", e.addr, err)
+	}
+	_ = conn.SetDeadline(time.Time{})
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// Close closes the shared connection.
+func (e *environment) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.client == nil {
+		return nil
+	}
+	err := e.client.Close()
+	e.client = nil
+	return err
+}
+
+// shellQuote quotes s for safe use as a single shell word.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,283 @@
+package ssh
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer is an in-process SSH server running exec requests with the
+// local bash.
+type testServer struct {
+	addr    string
+	hostKey ssh.PublicKey
+	conns   atomic.Int32
+}
+
+// newTestServer starts a server and returns it with the path of the only
+// private key it accepts.
+func newTestServer(t *testing.T) (*testServer, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+
+	_, hostPriv, _ := ed25519.GenerateKey(rand.Reader)
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientPub, clientPriv, _ := ed25519.GenerateKey(rand.Reader)
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	authorized, _ := ssh.NewPublicKey(clientPub)
+
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if string(key.Marshal()) != string(authorized.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &testServer{addr: ln.Addr().String(), hostKey: hostSigner.PublicKey()}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.conns.Add(1)
+			go s.serve(conn, cfg)
+		}
+	}()
+	return s, keyFile
+}
+
+func (s *testServer) serve(conn net.Conn, cfg *ssh.ServerConfig) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "session only")
+			continue
+		}
+		ch, requests, err := nc.Accept()
+		if err != nil {
+			continue
+		}
+		go s.session(ch, requests)
+	}
+}
+
+// session runs the first exec request, killing it on a signal request
+// or when the client closes the channel.
+func (s *testServer) session(ch ssh.Channel, requests <-chan *ssh.Request) {
+	defer ch.Close()
+
+	var cmd *exec.Cmd
+	done := make(chan struct{})
+	for req := range requests {
+		switch req.Type {
+		case "exec":
+			var payload struct{ Command string }
+			ssh.Unmarshal(req.Payload, &payload)
+			req.Reply(true, nil)
+
+			cmd = exec.Command("bash", "-c", payload.Command)
+			cmd.Stdout = ch
+			cmd.Stderr = ch.Stderr()
+			go func() {
+				defer close(done)
+				status := 0
+				if err := cmd.Run(); err != nil {
+					status = 255
+					var exitErr *exec.ExitError
+					if errors.As(err, &exitErr) && exitErr.ExitCode() >= 0 {
+						status = exitErr.ExitCode()
+					}
+				}
+				b := make([]byte, 4)
+				binary.BigEndian.PutUint32(b, uint32(status))
+				ch.SendRequest("exit-status", false, b)
+				ch.Close()
+			}()
+		case "signal":
+			if cmd != nil && cmd.Process != nil {
+				cmd.Process.Kill()
+			}
+			req.Reply(true, nil)
+		default:
+			req.Reply(false, nil)
+		}
+	}
+	if cmd != nil && cmd.Process != nil {
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+func newTestEnv(t *testing.T, cfg Config) (executor.ClosableEnvironment, *testServer) {
+	t.Helper()
+	s, keyFile := newTestServer(t)
+	env, err := New(cfg.WithHost(s.addr).WithUser("agent").WithKeyFile(keyFile).WithInsecureIgnoreHostKey())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	t.Cleanup(func() { env.Close() })
+	return env, s
+}
+
+func bash(command string) executor.Action {
+	return executor.Action{Type: executor.ActionTypeBash, Command: command}
+}
+
+func TestExecuteReturnsOutputAndReusesConnection(t *testing.T) {
+	env, s := newTestEnv(t, NewConfig())
+
+	output, err := env.Execute(context.Background(), bash("echo out; echo err >&2"))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if output.Stdout != "out\n" || output.Stderr != "err\n" {
+		t.Errorf("output = %+v, want stdout out and stderr err", output)
+	}
+
+	if _, err := env.Execute(context.Background(), bash("true")); err != nil {
+		t.Fatalf("second Execute() error = %v", err)
+	}
+	if n := s.conns.Load(); n != 1 {
+		t.Errorf("opened %d connections, want 1 reused across commands", n)
+	}
+}
+
+func TestExecuteReportsRemoteExitCode(t *testing.T) {
+	env, _ := newTestEnv(t, NewConfig())
+
+	output, err := env.Execute(context.Background(), bash("echo partial; exit 3"))
+	var execErr *executor.ExecutionError
+	if !errors.As(err, &execErr) || execErr.Type != executor.ErrExecution {
+		t.Fatalf("Execute() error = %v, want an execution error", err)
+	}
+	if output.ExitCode != 3 || output.Stdout != "partial\n" {
+		t.Errorf("output = %+v, want exit code 3 with the partial output", output)
+	}
+}
+
+func TestExecuteUsesWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	env, _ := newTestEnv(t, NewConfig().WithWorkingDir(dir))
+
+	output, err := env.Execute(context.Background(), bash("pwd"))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if got := strings.TrimSpace(output.Stdout); got != dir {
+		t.Errorf("pwd = %q, want %q", got, dir)
+	}
+}
+
+func TestExecuteTimesOut(t *testing.T) {
+	env, _ := newTestEnv(t, NewConfig().WithTimeout(200*time.Millisecond))
+
+	start := time.Now()
+	output, err := env.Execute(context.Background(), bash("echo started; sleep 10"))
+	var execErr *executor.ExecutionError
+	if !errors.As(err, &execErr) || execErr.Type != executor.ErrTimeout {
+		t.Fatalf("Execute() error = %v, want a timeout", err)
+	}
+	if !output.TimedOut {
+		t.Error("TimedOut = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Execute() took %s, want it to stop at the timeout", elapsed)
+	}
+}
+
+func TestExecuteValidatesBeforeConnecting(t *testing.T) {
+	env, s := newTestEnv(t, NewConfig())
+
+	_, err := env.Execute(context.Background(), bash("rm -rf /"))
+	var execErr *executor.ExecutionError
+	if !errors.As(err, &execErr) || execErr.Type != executor.ErrBlocked {
+		t.Fatalf("Execute() error = %v, want a blocked command", err)
+	}
+	if n := s.conns.Load(); n != 0 {
+		t.Errorf("opened %d connections for a blocked command, want 0", n)
+	}
+}
+
+func TestExecuteRejectsUnknownHostKey(t *testing.T) {
+	s, keyFile := newTestServer(t)
+
+	// known_hosts lists a different key for the server's address
+	otherPub, _, _ := ed25519.GenerateKey(rand.Reader)
+	other, _ := ssh.NewPublicKey(otherPub)
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(s.addr)}, other)
+	if err := os.WriteFile(knownHosts, []byte(line+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := New(NewConfig().WithHost(s.addr).WithUser("agent").WithKeyFile(keyFile).WithKnownHostsFile(knownHosts))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	defer env.Close()
+
+	if _, err := env.Execute(context.Background(), bash("true")); err == nil || !strings.Contains(err.Error(), "handshake") {
+		t.Errorf("Execute() error = %v, want a failed handshake", err)
+	}
+}
+
+func TestNewRequiresHostUserAndKey(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{"no host", NewConfig().WithUser("u").WithKeyFile("k")},
+		{"no user", NewConfig().WithHost("h").WithKeyFile("k")},
+		{"no key", NewConfig().WithHost("h").WithUser("u")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := New(tt.cfg); err == nil {
+				t.Error("New() error = nil, want a missing setting reported")
+			}
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package executor
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/rs/zerolog"
+)
+
+// warnOnly logs what the wrapped validator would block.
+type warnOnly struct {
+	v   CommandValidator
+	log *zerolog.Logger
+}
+
+// WarnOnly wraps v so that commands it would block are logged as warnings
+// and allowed to run, e.g. to tune a blocklist before enforcing it.
+func WarnOnly(v CommandValidator, log *zerolog.Logger) CommandValidator {
+	if log == nil {
+		l := zerolog.Nop()
+		log = &l
+	}
+	return &warnOnly{v: v, log: log}
+}
+
+// Validate implements CommandValidator and never fails.
+func (w *warnOnly) Validate(command string) error {
+	err := w.v.Validate(command)
+	if err == nil {
+		return nil
+	}
+
+	event := w.log.Warn().
+		Str("command", command).
+		Str("reason", err.Error())
+	var execErr *ExecutionError
+	if errors.As(err, &execErr) && execErr.Pattern != "" {
+		event = event.Str("pattern", execErr.Pattern)
+	}
+	event.Msg("command would be blocked")
+
+	return nil
+}
+
+// MapValidators returns v with f applied to each validator it combines,
+// looking inside chains and WarnOnly wrappers, so a wrapped validator can
+// be replaced without losing the wrapping.
+func MapValidators(v CommandValidator, f func(CommandValidator) CommandValidator) CommandValidator {
+	switch v := v.(type) {
+	case ValidatorChain:
+		mapped := make(ValidatorChain, len(v))
+		for i, inner := range v {
+			mapped[i] = MapValidators(inner, f)
+		}
+		return mapped
+	case *warnOnly:
+		return &warnOnly{v: MapValidators(v.v, f), log: v.log}
+	}
+	return f(v)
+}
+
+// ValidatorChain runs validators in order, failing on the first error.
+type ValidatorChain []CommandValidator
+
+// ChainValidator combines validators so a command must pass all of them.
+// An empty chain allows every command.
+func ChainValidator(validators ...CommandValidator) ValidatorChain {
+	return ValidatorChain(slices.Clone(validators))
+}
+
+// Validate implements CommandValidator.
+func (c ValidatorChain) Validate(command string) error {
+	for _, v := range c {
+		if err := v.Validate(command); err != nil {
+			return err
+		}
+	}
+	return nil
+}
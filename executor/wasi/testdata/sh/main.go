@@ -0,0 +1,48 @@
+// Command sh is a tiny stand-in for a shell compiled to WASI, used by the
+// wasi environment tests. It runs the last argument as one of a few
+// built-in commands.
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func main() {
+	command := os.Args[len(os.Args)-1]
+	name, arg, _ := strings.Cut(command, " ")
+	switch name {
+	case "echo":
+		fmt.Println(arg)
+	case "cat":
+		b, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(b)
+	case "write":
+		path, content, _ := strings.Cut(arg, " ")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+	case "env":
+		fmt.Println(os.Getenv(arg))
+	case "year":
+		fmt.Println(time.Now().Year())
+	case "exit":
+		code, _ := strconv.Atoi(arg)
+		fmt.Fprintln(os.Stderr, "exiting")
+		os.Exit(code)
+	case "loop":
+		for {
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "%s: command not found\n", name)
+		os.Exit(127)
+	}
+}
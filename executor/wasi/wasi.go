@@ -0,0 +1,278 @@
+// Package wasi runs commands in a WebAssembly module, such as a shell
+// compiled to WASI, using the wazero runtime. Commands can only reach the
+// mounted working directory, with no network access, and clocks and
+// randomness are deterministic unless enabled.
+package wasi
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/executor/local"
+)
+
+// Config holds the environment configuration.
+type Config struct {
+	module     []byte
+	modulePath string
+	args       []string
+	workingDir string
+	readOnly   bool
+	env        map[string]string
+	sysClock   bool
+	timeout    time.Duration
+	validator  executor.CommandValidator
+}
+
+// NewConfig creates a new Config with sensible defaults.
+func NewConfig() Config {
+	return Config{
+		args:      []string{"sh", "-c"},
+		timeout:   30 * time.Second,
+		validator: local.NewDefaultValidator(),
+	}
+}
+
+// WithModule sets the WebAssembly binary to run, e.g. a shell or
+// utilities compiled to WASI.
+func (c Config) WithModule(wasm []byte) Config {
+	c.module = wasm
+	return c
+}
+
+// WithModuleFile reads the WebAssembly binary from path on first use.
+func (c Config) WithModuleFile(path string) Config {
+	c.modulePath = path
+	return c
+}
+
+// WithArgs sets the program name and leading arguments the module is
+// invoked with; the command is passed as the final argument. Defaults to
+// sh -c.
+func (c Config) WithArgs(args ...string) Config {
+	c.args = slices.Clone(args)
+	return c
+}
+
+// WithWorkingDir sets the host directory mounted as the module's root
+// and working directory. It is the only part of the host filesystem
+// commands can reach. Defaults to the current directory.
+func (c Config) WithWorkingDir(dir string) Config {
+	c.workingDir = dir
+	return c
+}
+
+// WithReadOnly mounts the working directory read-only.
+func (c Config) WithReadOnly(enabled bool) Config {
+	c.readOnly = enabled
+	return c
+}
+
+// WithEnv sets the environment variables commands see. The host
+// environment is never inherited.
+func (c Config) WithEnv(env map[string]string) Config {
+	c.env = maps.Clone(env)
+	return c
+}
+
+// WithSystemClock gives commands the real wall clock and sleep, instead
+// of wazero's deterministic fakes, at the cost of reproducibility.
+func (c Config) WithSystemClock(enabled bool) Config {
+	c.sysClock = enabled
+	return c
+}
+
+// WithTimeout sets the command timeout.
+func (c Config) WithTimeout(d time.Duration) Config {
+	c.timeout = d
+	return c
+}
+
+// WithValidator sets a custom command validator.
+func (c Config) WithValidator(v executor.CommandValidator) Config {
+	c.validator = v
+	return c
+}
+
+// WithoutValidation disables command validation.
+func (c Config) WithoutValidation() Config {
+	c.validator = nil
+	return c
+}
+
+// environment implements the ClosableEnvironment interface (unexported).
+type environment struct {
+	cfg      Config
+	mu       sync.Mutex
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+}
+
+// New creates a new WASI environment. The module is compiled lazily on
+// the first Execute, and released by Close. Each command runs in a fresh
+// instance of the module, so no state but the filesystem carries over.
+func New(cfg Config) executor.ClosableEnvironment {
+	// Apply defaults if zero values
+	if cfg.args == nil {
+		cfg.args = []string{"sh", "-c"}
+	}
+	if cfg.workingDir == "" {
+		cfg.workingDir = "."
+	}
+	if cfg.timeout == 0 {
+		cfg.timeout = 30 * time.Second
+	}
+	return &environment{cfg: cfg}
+}
+
+// Execute runs a command as an invocation of the module.
+func (e *environment) Execute(ctx context.Context, action executor.Action) (executor.Output, error) {
+	if action.Type != executor.ActionTypeBash {
+		return executor.Output{}, executor.UnsupportedAction(action)
+	}
+	if executor.HasInputRefs(action.Command) {
+		return executor.Output{}, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: "#INPUT references are not supported in the wasi environment.",
+			Command: action.Command,
+		}
+	}
+
+	// Validate command before execution
+	if e.cfg.validator != nil {
+		if err := e.cfg.validator.Validate(action.Command); err != nil {
+			return executor.Output{}, err
+		}
+	}
+
+	runtime, compiled, err := e.ensureModule(ctx)
+	if err != nil {
+		return executor.Output{}, err
+	}
+
+	timeout := action.TimeoutOr(e.cfg.timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	fsCfg := wazero.NewFSConfig().WithDirMount(e.cfg.workingDir, "/")
+	if e.cfg.readOnly {
+		fsCfg = wazero.NewFSConfig().WithReadOnlyDirMount(e.cfg.workingDir, "/")
+	}
+	// An empty name lets instances run concurrently
+	modCfg := wazero.NewModuleConfig().
+		WithName("").
+		WithArgs(append(slices.Clone(e.cfg.args), action.Command)...).
+		WithStdout(&stdout).
+		WithStderr(&stderr).
+		WithFSConfig(fsCfg)
+	for _, k := range slices.Sorted(maps.Keys(e.cfg.env)) {
+		modCfg = modCfg.WithEnv(k, e.cfg.env[k])
+	}
+	if e.cfg.sysClock {
+		modCfg = modCfg.WithSysWalltime().WithSysNanotime().WithSysNanosleep()
+	}
+
+	start := time.Now()
+	mod, err := runtime.InstantiateModule(timeoutCtx, compiled, modCfg)
+	if mod != nil {
+		mod.Close(context.WithoutCancel(ctx))
+	}
+
+	output := executor.Output{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Duration: time.Since(start),
+	}
+
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 0 {
+		err = nil
+	}
+	if err != nil {
+		// Check if it was a timeout
+		if errors.Is(timeoutCtx.Err(), context.DeadlineExceeded) {
+			output.TimedOut = true
+			return output, &executor.ExecutionError{
+				Type:    executor.ErrTimeout,
+				Message: fmt.Sprintf("Command timed out after %s. Partial output:\n%s", timeout, output.String()),
+			}
+		}
+
+		if exitErr != nil {
+			output.ExitCode = int(exitErr.ExitCode())
+		}
+
+		return output, &executor.ExecutionError{
+			Type:    executor.ErrExecution,
+			Message: fmt.Sprintf("Command failed: %s\nOutput:\n%s", err.Error(), output.String()),
+		}
+	}
+
+	return output, nil
+}
+
+// ensureModule creates the runtime and compiles the module on first use.
+func (e *environment) ensureModule(ctx context.Context) (wazero.Runtime, wazero.CompiledModule, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.compiled != nil {
+		return e.runtime, e.compiled, nil
+	}
+
+	wasm := e.cfg.module
+	if wasm == nil {
+		if e.cfg.modulePath == "" {
+			return nil, nil, errors.New("no WASI module configured")
+		}
+		var err error
+		if wasm, err = os.ReadFile(e.cfg.modulePath); err != nil {
+			return nil, nil, fmt.Errorf("failed to read WASI module: %w", err)
+		}
+	}
+
+	// Cancelling a command's context stops the module mid-execution
+	runtime := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().WithCloseOnContextDone(true))
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, runtime); err != nil {
+		runtime.Close(ctx)
+		return nil, nil, fmt.Errorf("failed to instantiate WASI: %w", err)
+	}
+	compiled, err := runtime.CompileModule(ctx, wasm)
+	if err != nil {
+		runtime.Close(ctx)
+		return nil, nil, fmt.Errorf("failed to compile WASI module: %w", err)
+	}
+
+	e.runtime, e.compiled = runtime, compiled
+	return runtime, compiled, nil
+}
+
+// Close releases the runtime and the compiled module.
+func (e *environment) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.runtime == nil {
+		return nil
+	}
+
+	err := e.runtime.Close(context.Background())
+	e.runtime, e.compiled = nil, nil
+	if err != nil {
+		return fmt.Errorf("failed to close WASI runtime: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package wasi
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor"
+)
+
+var (
+	buildOnce  sync.Once
+	modulePath string
+	buildErr   error
+)
+
+// testModule compiles testdata/sh to WASI once per test run.
+func testModule(t *testing.T) string {
+	t.Helper()
+	buildOnce.Do(func() {
+		goBin, err := exec.LookPath("go")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		dir, err := os.MkdirTemp("", "wise-wasi-test-")
+		if err != nil {
+			buildErr = err
+			return
+		}
+		modulePath = filepath.Join(dir, "sh.wasm")
+		cmd := exec.Command(goBin, "build", "-o", modulePath, "./testdata/sh")
+		cmd.Env = append(os.Environ(), "GOOS=wasip1", "GOARCH=wasm")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			buildErr = errors.New(string(out))
+		}
+	})
+	if buildErr != nil {
+		t.Skipf("can't build the test module: %v", buildErr)
+	}
+	return modulePath
+}
+
+func newTestEnv(t *testing.T, cfg Config) executor.ClosableEnvironment {
+	t.Helper()
+	env := New(cfg.WithModuleFile(testModule(t)))
+	t.Cleanup(func() { env.Close() })
+	return env
+}
+
+func bash(command string) executor.Action {
+	return executor.Action{Type: executor.ActionTypeBash, Command: command}
+}
+
+func TestExecuteRunsModule(t *testing.T) {
+	env := newTestEnv(t, NewConfig().WithWorkingDir(t.TempDir()))
+
+	output, err := env.Execute(context.Background(), bash("echo hello"))
+	if err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+	if output.Stdout != "hello\n" {
+		t.Errorf("stdout = %q, want %q", output.Stdout, "hello\n")
+	}
+}
+
+func TestExecuteReportsExitCode(t *testing.T) {
+	env := newTestEnv(t, NewConfig().WithWorkingDir(t.TempDir()))
+
+	output, err := env.Execute(context.Background(), bash("exit 3"))
+	var execErr *executor.ExecutionError
+	if !errors.As(err, &execErr) || execErr.Type != executor.ErrExecution {
+		t.Fatalf("Execute() error = %v, want an execution error", err)
+	}
+	if output.ExitCode != 3 || output.Stderr != "exiting\n" {
+		t.Errorf("output = %+v, want exit code 3 with stderr", output)
+	}
+}
+
+func TestExecuteFilesystemIsConfinedToWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	env := newTestEnv(t, NewConfig().WithWorkingDir(dir))
+
+	if _, err := env.Execute(context.Background(), bash("write notes.txt kept")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "notes.txt")); string(got) != "kept" {
+		t.Errorf("notes.txt = %q, want the module's write on the host", got)
+	}
+
+	// Each command runs in a fresh instance; only the filesystem carries over
+	output, err := env.Execute(context.Background(), bash("cat notes.txt"))
+	if err != nil || output.Stdout != "kept" {
+		t.Errorf("cat = %q, %v, want kept", output.Stdout, err)
+	}
+
+	outside := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(outside, []byte("secret"), 0o644)
+	if output, err := env.Execute(context.Background(), bash("cat "+outside)); err == nil {
+		t.Errorf("read a host file outside the mount: %q", output.Stdout)
+	}
+}
+
+func TestExecuteReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	env := newTestEnv(t, NewConfig().WithWorkingDir(dir).WithReadOnly(true))
+
+	if _, err := env.Execute(context.Background(), bash("write notes.txt x")); err == nil {
+		t.Error("write succeeded on a read-only mount")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "notes.txt")); !os.IsNotExist(err) {
+		t.Errorf("notes.txt exists: %v", err)
+	}
+}
+
+func TestExecuteEnvAndClock(t *testing.T) {
+	env := newTestEnv(t, NewConfig().WithWorkingDir(t.TempDir()).WithEnv(map[string]string{"GREETING": "hi"}))
+
+	output, err := env.Execute(context.Background(), bash("env GREETING"))
+	if err != nil || output.Stdout != "hi\n" {
+		t.Errorf("env = %q, %v, want hi", output.Stdout, err)
+	}
+
+	// The clock is deterministic unless the system clock is enabled
+	year := strconv.Itoa(time.Now().Year()) + "\n"
+	if output, _ := env.Execute(context.Background(), bash("year")); output.Stdout == year {
+		t.Errorf("year = %q, want the deterministic clock", output.Stdout)
+	}
+	sys := newTestEnv(t, NewConfig().WithWorkingDir(t.TempDir()).WithSystemClock(true))
+	if output, _ := sys.Execute(context.Background(), bash("year")); output.Stdout != year {
+		t.Errorf("year = %q, want %q from the system clock", output.Stdout, year)
+	}
+}
+
+func TestExecuteTimesOut(t *testing.T) {
+	env := newTestEnv(t, NewConfig().WithWorkingDir(t.TempDir()).WithTimeout(200*time.Millisecond))
+	// Compile the module first, which the timeout doesn't cover
+	if _, err := env.Execute(context.Background(), bash("echo warm")); err != nil {
+		t.Fatalf("Execute() error = %v", err)
+	}
+
+	start := time.Now()
+	output, err := env.Execute(context.Background(), bash("loop"))
+	var execErr *executor.ExecutionError
+	if !errors.As(err, &execErr) || execErr.Type != executor.ErrTimeout {
+		t.Fatalf("Execute() error = %v, want a timeout", err)
+	}
+	if !output.TimedOut {
+		t.Error("TimedOut = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Execute() took %s, want it stopped at the timeout", elapsed)
+	}
+}
+
+func TestExecuteRejectsInputRefsAndBlockedCommands(t *testing.T) {
+	env := New(NewConfig().WithModule([]byte("not wasm")))
+	defer env.Close()
+
+	for _, command := range []string{"cat #INPUT:data", "rm -rf /"} {
+		var execErr *executor.ExecutionError
+		if _, err := env.Execute(context.Background(), bash(command)); !errors.As(err, &execErr) {
+			t.Errorf("Execute(%q) error = %v, want an ExecutionError before the module loads", command, err)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package wise
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExportMessages writes the conversation history as a JSON array of
+// {"role": ..., "content": ...} objects, the chat format used by OpenAI
+// and Anthropic tooling, e.g. for building fine-tuning datasets. Roles
+// are system, user and assistant; responses from native tool calls keep
+// their encoded form.
+func (a *baseAgent) ExportMessages(w io.Writer) error {
+	messages := a.history()
+	if messages == nil {
+		messages = []Message{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(messages); err != nil {
+		return fmt.Errorf("failed to export messages: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package wise
+
+import "time"
+
+// Hooks holds optional callbacks invoked as the agent runs.
+// Nil callbacks are skipped. Steps are numbered from 1. OnAction sees a
+// write action's DisplayCommand in place of the file content.
+type Hooks struct {
+	OnStepStart     func(step int)
+	OnModelResponse func(step int, response string)
+	OnThought       func(step int, thought string)
+	OnQuery         func(duration time.Duration, usage TokenUsage, err error)
+	OnAction        func(action Action)
+	OnObservation   func(output Output)
+	OnTerminate     func(reason TerminationReason)
+}
+
+func (h Hooks) stepStart(step int) {
+	if h.OnStepStart != nil {
+		h.OnStepStart(step)
+	}
+}
+
+func (h Hooks) modelResponse(step int, response string) {
+	if h.OnModelResponse != nil {
+		h.OnModelResponse(step, response)
+	}
+}
+
+func (h Hooks) thought(step int, thought string) {
+	if h.OnThought != nil {
+		h.OnThought(step, thought)
+	}
+}
+
+func (h Hooks) query(duration time.Duration, usage TokenUsage, err error) {
+	if h.OnQuery != nil {
+		h.OnQuery(duration, usage, err)
+	}
+}
+
+func (h Hooks) action(action Action) {
+	if h.OnAction != nil {
+		h.OnAction(action)
+	}
+}
+
+func (h Hooks) observation(output Output) {
+	if h.OnObservation != nil {
+		h.OnObservation(output)
+	}
+}
+
+func (h Hooks) terminate(reason TerminationReason) {
+	if h.OnTerminate != nil {
+		h.OnTerminate(reason)
+	}
+}
+
+// CombineHooks returns Hooks calling each of the given hooks in order.
+func CombineHooks(hooks ...Hooks) Hooks {
+	return Hooks{
+		OnStepStart: func(step int) {
+			for _, h := range hooks {
+				h.stepStart(step)
+			}
+		},
+		OnModelResponse: func(step int, response string) {
+			for _, h := range hooks {
+				h.modelResponse(step, response)
+			}
+		},
+		OnThought: func(step int, thought string) {
+			for _, h := range hooks {
+				h.thought(step, thought)
+			}
+		},
+		OnQuery: func(duration time.Duration, usage TokenUsage, err error) {
+			for _, h := range hooks {
+				h.query(duration, usage, err)
+			}
+		},
+		OnAction: func(action Action) {
+			for _, h := range hooks {
+				h.action(action)
+			}
+		},
+		OnObservation: func(output Output) {
+			for _, h := range hooks {
+				h.observation(output)
+			}
+		},
+		OnTerminate: func(reason TerminationReason) {
+			for _, h := range hooks {
+				h.terminate(reason)
+			}
+		},
+	}
+}
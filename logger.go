@@ -0,0 +1,20 @@
+package wise
+
+import "github.com/rs/zerolog"
+
+// Log components, added as the "component" field on log events.
+const (
+	ComponentAgent    = "agent"
+	ComponentModel    = "model"
+	ComponentExecutor = "executor"
+)
+
+// componentLogger derives a child logger tagged with the component name.
+// A level in levels overrides the base logger's level for that component.
+func componentLogger(base *zerolog.Logger, component string, levels map[string]zerolog.Level) *zerolog.Logger {
+	l := base.With().Str("component", component).Logger()
+	if level, ok := levels[component]; ok {
+		l = l.Level(level)
+	}
+	return &l
+}
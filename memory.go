@@ -0,0 +1,164 @@
+package wise
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/j0lvera/wise/models"
+)
+
+// MemoryStrategy reduces the conversation before it is sent to the model.
+// Implementations must keep the leading system prompt and original task.
+type MemoryStrategy interface {
+	Trim(ctx context.Context, messages []Message) ([]Message, error)
+}
+
+// UsageReporter is implemented by memory strategies that query a model.
+// LastUsage returns the tokens spent by the most recent Trim, which the
+// agent adds to its usage and cost at the agent model's price.
+type UsageReporter interface {
+	LastUsage() TokenUsage
+}
+
+// DropOldestMemory drops the oldest messages once the conversation
+// exceeds a maximum number of messages.
+type DropOldestMemory struct {
+	maxMessages int
+}
+
+// NewDropOldestMemory creates a strategy keeping at most maxMessages,
+// counting the system prompt and task.
+func NewDropOldestMemory(maxMessages int) *DropOldestMemory {
+	return &DropOldestMemory{maxMessages: maxMessages}
+}
+
+// Trim drops the oldest messages after the system prompt and task.
+func (m *DropOldestMemory) Trim(_ context.Context, messages []Message) ([]Message, error) {
+	if m.maxMessages <= 0 || len(messages) <= m.maxMessages {
+		return messages, nil
+	}
+
+	prefix := preservedPrefix(messages)
+	start := len(messages) - (m.maxMessages - prefix)
+	if start < prefix {
+		start = prefix
+	}
+	// Resume on an assistant turn so roles keep alternating after the task
+	for start < len(messages)-1 && messages[start].Role != RoleAssistant {
+		start++
+	}
+
+	trimmed := make([]Message, 0, prefix+len(messages)-start)
+	trimmed = append(trimmed, messages[:prefix]...)
+	trimmed = append(trimmed, messages[start:]...)
+	return trimmed, nil
+}
+
+// preservedPrefix returns the length of the leading system messages and
+// the original task, which trimming never removes.
+func preservedPrefix(messages []Message) int {
+	for i, m := range messages {
+		if m.Role == RoleUser {
+			return i + 1
+		}
+	}
+	return len(messages)
+}
+
+// summarizePrompt instructs the model to condense part of a transcript.
+const summarizePrompt = `You condense transcripts of an autonomous agent executing bash commands.
+Summarize the messages below: the commands run, key results, files changed and any open problems.
+Be concise and factual. Respond with the summary only.`
+
+// summaryHeader introduces summaries appended to the system prompt.
+const summaryHeader = "Summary of earlier steps:"
+
+// SummarizingMemory asks the model to condense the oldest messages into
+// a summary appended to the system prompt once the conversation exceeds
+// a maximum size. Providers such as Anthropic reject system messages in
+// the middle of a conversation, so the summary isn't a message of its own.
+type SummarizingMemory struct {
+	model       models.Model
+	maxMessages int
+	batch       int
+	summarizing bool
+	usage       TokenUsage
+}
+
+// NewSummarizingMemory creates a strategy that, once the conversation
+// exceeds maxMessages, replaces the oldest batch messages after the
+// system prompt and task with a model-written summary.
+func NewSummarizingMemory(model models.Model, maxMessages, batch int) *SummarizingMemory {
+	return &SummarizingMemory{model: model, maxMessages: maxMessages, batch: batch}
+}
+
+// Trim summarizes the oldest messages when the limit is exceeded.
+func (m *SummarizingMemory) Trim(ctx context.Context, messages []Message) ([]Message, error) {
+	m.usage = TokenUsage{}
+	// Guard against re-entry if the summarization query triggers a trim
+	if m.summarizing || m.maxMessages <= 0 || len(messages) <= m.maxMessages {
+		return messages, nil
+	}
+
+	prefix := preservedPrefix(messages)
+	end := prefix + max(m.batch, len(messages)-m.maxMessages+1)
+	// Stop before an assistant turn so roles keep alternating after the summary
+	for end < len(messages)-1 && messages[end].Role != RoleAssistant {
+		end++
+	}
+	if end >= len(messages) {
+		return messages, nil
+	}
+
+	m.summarizing = true
+	summary, err := m.summarize(ctx, messages[prefix:end])
+	m.summarizing = false
+	if err != nil {
+		return nil, fmt.Errorf("failed to summarize history: %w", err)
+	}
+
+	trimmed := make([]Message, 0, prefix+1+len(messages)-end)
+	if messages[0].Role != RoleSystem {
+		trimmed = append(trimmed, Message{Role: RoleSystem})
+	}
+	trimmed = append(trimmed, messages[:prefix]...)
+	trimmed[0].Content = appendSummary(trimmed[0].Content, summary)
+	trimmed = append(trimmed, messages[end:]...)
+	return trimmed, nil
+}
+
+// LastUsage implements UsageReporter.
+func (m *SummarizingMemory) LastUsage() TokenUsage {
+	return m.usage
+}
+
+// appendSummary adds a summary to the system prompt, after any earlier
+// summaries.
+func appendSummary(system, summary string) string {
+	if !strings.Contains(system, summaryHeader) {
+		summary = summaryHeader + "\n" + summary
+	}
+	if system == "" {
+		return summary
+	}
+	return system + "\n\n" + summary
+}
+
+// summarize asks the model to condense the given messages.
+func (m *SummarizingMemory) summarize(ctx context.Context, messages []Message) (string, error) {
+	var transcript strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&transcript, "[%s]\n%s\n\n", msg.Role, msg.Content)
+	}
+
+	summary, usage, err := m.model.Query(ctx, []Message{
+		{Role: RoleSystem, Content: summarizePrompt},
+		{Role: RoleUser, Content: transcript.String()},
+	})
+	m.usage = usage
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(summary), nil
+}
@@ -0,0 +1,90 @@
+package wise
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/j0lvera/wise/executor/echo"
+)
+
+// summaryModel answers every query with a fixed summary and usage.
+type summaryModel struct {
+	usage TokenUsage
+}
+
+func (m summaryModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	return "ran the first commands", m.usage, nil
+}
+
+func TestSummarizingMemoryAppendsSummaryToSystemPrompt(t *testing.T) {
+	messages := []Message{
+		{Role: RoleSystem, Content: "system prompt"},
+		{Role: RoleUser, Content: "task"},
+	}
+	for i := range 4 {
+		messages = append(messages,
+			Message{Role: RoleAssistant, Content: fmt.Sprintf("```bash\necho %d\n```", i)},
+			Message{Role: RoleUser, Content: fmt.Sprint(i)},
+		)
+	}
+	usage := TokenUsage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}
+	m := NewSummarizingMemory(summaryModel{usage: usage}, 6, 2)
+
+	trimmed, err := m.Trim(context.Background(), messages)
+	if err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+
+	want := []string{RoleSystem, RoleUser, RoleAssistant, RoleUser}
+	if got := roles(trimmed); !slices.Equal(got, want) {
+		t.Fatalf("roles = %v, want %v", got, want)
+	}
+	if want := "system prompt\n\n" + summaryHeader + "\nran the first commands"; trimmed[0].Content != want {
+		t.Errorf("system prompt = %q, want %q", trimmed[0].Content, want)
+	}
+	if messages[0].Content != "system prompt" {
+		t.Errorf("Trim modified the caller's system prompt: %q", messages[0].Content)
+	}
+	if got := m.LastUsage(); got != usage {
+		t.Errorf("LastUsage() = %+v, want %+v", got, usage)
+	}
+
+	// A second summary follows the first under the same header
+	again, err := m.Trim(context.Background(), append(trimmed, messages[2:6]...))
+	if err != nil {
+		t.Fatalf("Trim() error = %v", err)
+	}
+	if n := strings.Count(again[0].Content, summaryHeader); n != 1 {
+		t.Errorf("system prompt has %d summary headers, want 1: %q", n, again[0].Content)
+	}
+}
+
+func TestSummarizingMemoryUsageCountsTowardsTotals(t *testing.T) {
+	var responses []string
+	for i := range 4 {
+		responses = append(responses, fmt.Sprintf("```bash\necho %d\n```", i))
+	}
+	responses = append(responses, "```bash\nTASK_COMPLETE\n```")
+	usage := TokenUsage{PromptTokens: 100, CompletionTokens: 10, TotalTokens: 110}
+	cfg := NewConfig().WithMemoryStrategy(NewSummarizingMemory(summaryModel{usage: usage}, 6, 2))
+	a, err := New(&scriptedModel{responses: responses}, echo.New(echo.NewConfig()), cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if got := a.Usage(); got.TotalTokens == 0 || got.TotalTokens%usage.TotalTokens != 0 {
+		t.Errorf("Usage() = %+v, want it to include the summarization queries", got)
+	}
+	for _, m := range a.Messages()[1:] {
+		if m.Role == RoleSystem {
+			t.Errorf("conversation has a system message after the first: %q", m.Content)
+		}
+	}
+}
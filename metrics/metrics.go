@@ -0,0 +1,120 @@
+// Package metrics exposes Prometheus metrics for agent runs.
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/j0lvera/wise"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector updates Prometheus metrics from agent hooks.
+type Collector struct {
+	model         string
+	steps         *prometheus.CounterVec
+	commands      *prometheus.CounterVec
+	failures      *prometheus.CounterVec
+	queryDuration *prometheus.HistogramVec
+	cmdDuration   *prometheus.HistogramVec
+	tokens        *prometheus.CounterVec
+}
+
+// NewCollector registers the agent metrics with reg, labelled with the
+// model name. A nil reg yields a collector whose hooks do nothing.
+// Collectors for several models can share one registry.
+func NewCollector(reg prometheus.Registerer, model string) (*Collector, error) {
+	if reg == nil {
+		return &Collector{model: model}, nil
+	}
+
+	c := &Collector{model: model}
+	var err error
+
+	if c.steps, err = register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "steps_total",
+		Help: "Agent steps started.",
+	}, []string{"model"})); err != nil {
+		return nil, err
+	}
+	if c.commands, err = register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "commands_executed_total",
+		Help: "Commands executed by the agent.",
+	}, []string{"model"})); err != nil {
+		return nil, err
+	}
+	if c.failures, err = register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "command_failures_total",
+		Help: "Commands that exited non-zero or timed out.",
+	}, []string{"model"})); err != nil {
+		return nil, err
+	}
+	if c.queryDuration, err = register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "model_query_duration_seconds",
+		Help:    "Duration of model queries.",
+		Buckets: prometheus.ExponentialBuckets(0.25, 2, 10),
+	}, []string{"model"})); err != nil {
+		return nil, err
+	}
+	if c.cmdDuration, err = register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "command_duration_seconds",
+		Help:    "Duration of commands, as measured by the environment.",
+		Buckets: prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"model"})); err != nil {
+		return nil, err
+	}
+	if c.tokens, err = register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "tokens_total",
+		Help: "Tokens used by model queries.",
+	}, []string{"model", "type"})); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// register registers v, reusing an identical collector already registered.
+func register[T prometheus.Collector](reg prometheus.Registerer, v T) (T, error) {
+	if err := reg.Register(v); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if errors.As(err, &are) {
+			if existing, ok := are.ExistingCollector.(T); ok {
+				return existing, nil
+			}
+		}
+		var zero T
+		return zero, err
+	}
+	return v, nil
+}
+
+// Hooks returns agent hooks that update the metrics. Combine with other
+// hooks via wise.CombineHooks.
+func (c *Collector) Hooks() wise.Hooks {
+	if c.steps == nil {
+		return wise.Hooks{}
+	}
+
+	return wise.Hooks{
+		OnStepStart: func(int) {
+			c.steps.WithLabelValues(c.model).Inc()
+		},
+		OnQuery: func(d time.Duration, usage wise.TokenUsage, _ error) {
+			c.queryDuration.WithLabelValues(c.model).Observe(d.Seconds())
+			c.tokens.WithLabelValues(c.model, "prompt").Add(float64(usage.PromptTokens))
+			c.tokens.WithLabelValues(c.model, "completion").Add(float64(usage.CompletionTokens))
+		},
+		OnAction: func(wise.Action) {
+			c.commands.WithLabelValues(c.model).Inc()
+		},
+		OnObservation: func(output wise.Output) {
+			if output.ExitCode != 0 || output.TimedOut {
+				c.failures.WithLabelValues(c.model).Inc()
+			}
+			if output.Duration > 0 {
+				c.cmdDuration.WithLabelValues(c.model).Observe(output.Duration.Seconds())
+			}
+		},
+	}
+}
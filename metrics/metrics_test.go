@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// value returns the sum of the samples of the named metric whose labels
+// include labels.
+func value(t *testing.T, reg *prometheus.Registry, name string, labels map[string]string) float64 {
+	t.Helper()
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather() error = %v", err)
+	}
+	var sum float64
+	for _, f := range families {
+		if f.GetName() != name {
+			continue
+		}
+		for _, m := range f.GetMetric() {
+			if !hasLabels(m, labels) {
+				continue
+			}
+			switch {
+			case m.Counter != nil:
+				sum += m.Counter.GetValue()
+			case m.Histogram != nil:
+				sum += float64(m.Histogram.GetSampleCount())
+			}
+		}
+	}
+	return sum
+}
+
+func hasLabels(m *dto.Metric, labels map[string]string) bool {
+	matched := 0
+	for _, l := range m.GetLabel() {
+		if v, ok := labels[l.GetName()]; ok && v == l.GetValue() {
+			matched++
+		}
+	}
+	return matched == len(labels)
+}
+
+func TestHooksUpdateMetrics(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	c, err := NewCollector(reg, "gpt")
+	if err != nil {
+		t.Fatalf("NewCollector() error = %v", err)
+	}
+	hooks := c.Hooks()
+
+	hooks.OnStepStart(1)
+	hooks.OnQuery(time.Second, wise.TokenUsage{PromptTokens: 100, CompletionTokens: 20}, nil)
+	hooks.OnAction(wise.Action{Command: "ls"})
+	hooks.OnObservation(wise.Output{Duration: time.Second})
+	hooks.OnAction(wise.Action{Command: "false"})
+	hooks.OnObservation(wise.Output{ExitCode: 1, Duration: time.Second})
+	hooks.OnAction(wise.Action{Command: "sleep 99"})
+	hooks.OnObservation(wise.Output{TimedOut: true})
+
+	model := map[string]string{"model": "gpt"}
+	tests := []struct {
+		name   string
+		labels map[string]string
+		want   float64
+	}{
+		{"steps_total", model, 1},
+		{"commands_executed_total", model, 3},
+		{"command_failures_total", model, 2},
+		{"model_query_duration_seconds", model, 1},
+		{"command_duration_seconds", model, 2},
+		{"tokens_total", map[string]string{"model": "gpt", "type": "prompt"}, 100},
+		{"tokens_total", map[string]string{"model": "gpt", "type": "completion"}, 20},
+	}
+	for _, tt := range tests {
+		if got := value(t, reg, tt.name, tt.labels); got != tt.want {
+			t.Errorf("%s%v = %v, want %v", tt.name, tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestCollectorsShareRegistry(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	a, err := NewCollector(reg, "a")
+	if err != nil {
+		t.Fatalf("NewCollector(a) error = %v", err)
+	}
+	b, err := NewCollector(reg, "b")
+	if err != nil {
+		t.Fatalf("NewCollector(b) error = %v", err)
+	}
+
+	a.Hooks().OnStepStart(1)
+	b.Hooks().OnStepStart(1)
+	b.Hooks().OnStepStart(2)
+
+	if got := value(t, reg, "steps_total", map[string]string{"model": "a"}); got != 1 {
+		t.Errorf("steps_total{model=a} = %v, want 1", got)
+	}
+	if got := value(t, reg, "steps_total", map[string]string{"model": "b"}); got != 2 {
+		t.Errorf("steps_total{model=b} = %v, want 2", got)
+	}
+}
+
+func TestNilRegistryDisablesHooks(t *testing.T) {
+	c, err := NewCollector(nil, "gpt")
+	if err != nil {
+		t.Fatalf("NewCollector() error = %v", err)
+	}
+	hooks := c.Hooks()
+	if hooks.OnStepStart != nil || hooks.OnQuery != nil || hooks.OnAction != nil || hooks.OnObservation != nil {
+		t.Error("Hooks() set callbacks without a registry")
+	}
+}
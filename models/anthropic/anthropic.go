@@ -0,0 +1,195 @@
+package anthropic
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/j0lvera/wise/models"
+	"github.com/j0lvera/wise/models/internal/apikey"
+	"github.com/j0lvera/wise/models/internal/llmerr"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+)
+
+// Config holds the model configuration.
+type Config struct {
+	apiKey  string
+	baseURL string
+}
+
+// NewConfig creates a new Config with defaults.
+func NewConfig() Config {
+	return Config{}
+}
+
+// WithAPIKey sets the API key.
+func (c Config) WithAPIKey(key string) Config {
+	c.apiKey = key
+	return c
+}
+
+// WithBaseURL sets the base URL for the API.
+func (c Config) WithBaseURL(url string) Config {
+	c.baseURL = url
+	return c
+}
+
+// model implements the Model interface (unexported).
+type model struct {
+	cfg    Config
+	name   string
+	client llms.Model
+}
+
+// New creates a new Anthropic model.
+// Falls back to ANTHROPIC_API_KEY and ANTHROPIC_BASE_URL env vars when not set via builder.
+// The key may also come from a file named by ANTHROPIC_API_KEY_FILE or the output
+// of the command in ANTHROPIC_API_KEY_CMD.
+func New(modelName string, cfg Config) (models.Model, error) {
+	if cfg.apiKey == "" {
+		key, err := apikey.FromEnv("ANTHROPIC_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		cfg.apiKey = key
+	}
+	if cfg.baseURL == "" {
+		cfg.baseURL = os.Getenv("ANTHROPIC_BASE_URL")
+	}
+
+	if cfg.apiKey == "" {
+		return nil, fmt.Errorf("API key is required (set via WithAPIKey or ANTHROPIC_API_KEY)")
+	}
+
+	clientOpts := []anthropic.Option{
+		anthropic.WithToken(cfg.apiKey),
+		anthropic.WithModel(modelName),
+	}
+	if cfg.baseURL != "" {
+		clientOpts = append(clientOpts, anthropic.WithBaseURL(cfg.baseURL))
+	}
+
+	client, err := anthropic.New(clientOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM client: %w", err)
+	}
+
+	return &model{cfg: cfg, name: modelName, client: client}, nil
+}
+
+// Name returns the model name.
+func (m *model) Name() string {
+	return m.name
+}
+
+// Query sends messages to the LLM and returns the response with token usage.
+func (m *model) Query(ctx context.Context, messages []models.Message) (string, models.TokenUsage, error) {
+	return m.generate(ctx, messages)
+}
+
+// QueryStream sends messages to the LLM, calling onChunk for each streamed
+// chunk, and returns the full response with token usage.
+func (m *model) QueryStream(ctx context.Context, messages []models.Message, onChunk func(chunk string) error) (string, models.TokenUsage, error) {
+	return m.generate(ctx, messages, llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		return onChunk(string(chunk))
+	}))
+}
+
+// generate converts messages and calls the underlying client.
+func (m *model) generate(ctx context.Context, messages []models.Message, opts ...llms.CallOption) (string, models.TokenUsage, error) {
+	resp, err := m.client.GenerateContent(ctx, convertMessages(messages), opts...)
+	if err != nil {
+		return "", models.TokenUsage{}, llmerr.Classify(fmt.Errorf("failed to generate content: %w", err))
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", models.TokenUsage{}, fmt.Errorf("no choices returned from model")
+	}
+
+	// Each content block (thinking, text) is returned as its own choice
+	var content strings.Builder
+	var usage models.TokenUsage
+	for _, choice := range resp.Choices {
+		content.WriteString(responseText(choice))
+		if u := extractTokenUsage(choice); u.TotalTokens > 0 {
+			usage = u
+		}
+	}
+
+	return content.String(), usage, nil
+}
+
+// responseText returns the text of a content block without the model's
+// thinking, so commands it only considered aren't parsed as actions.
+// Thinking blocks have none, and <thinking> tags in text blocks are
+// removed.
+func responseText(choice *llms.ContentChoice) string {
+	if _, ok := choice.GenerationInfo["ThinkingSignature"]; ok {
+		return ""
+	}
+	if thinking, _ := choice.GenerationInfo["ThinkingContent"].(string); thinking != "" {
+		if output, ok := choice.GenerationInfo["OutputContent"].(string); ok {
+			return output
+		}
+	}
+	return choice.Content
+}
+
+// convertMessages maps messages onto Anthropic's conversation shape.
+// Anthropic takes the system prompt as a top-level field rather than a
+// message, so all system messages are merged into a single leading one.
+// Consecutive messages with the same role are merged, since the API
+// expects user and assistant turns to alternate.
+func convertMessages(messages []models.Message) []llms.MessageContent {
+	var system []string
+	var turns []models.Message
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			system = append(system, msg.Content)
+		case "user", "assistant":
+			if n := len(turns); n > 0 && turns[n-1].Role == msg.Role {
+				turns[n-1].Content += "\n\n" + msg.Content
+				continue
+			}
+			turns = append(turns, msg)
+		}
+	}
+
+	llmMessages := make([]llms.MessageContent, 0, len(turns)+1)
+	if len(system) > 0 {
+		llmMessages = append(llmMessages, llms.TextParts(llms.ChatMessageTypeSystem, strings.Join(system, "\n\n")))
+	}
+	for _, msg := range turns {
+		msgType := llms.ChatMessageTypeHuman
+		if msg.Role == "assistant" {
+			msgType = llms.ChatMessageTypeAI
+		}
+		llmMessages = append(llmMessages, llms.TextParts(msgType, msg.Content))
+	}
+	return llmMessages
+}
+
+// extractTokenUsage pulls token counts from langchaingo's GenerationInfo map.
+func extractTokenUsage(choice *llms.ContentChoice) models.TokenUsage {
+	if choice.GenerationInfo == nil {
+		return models.TokenUsage{}
+	}
+
+	info := choice.GenerationInfo
+	usage := models.TokenUsage{}
+
+	if v, ok := info["InputTokens"].(int); ok {
+		usage.PromptTokens = v
+	}
+	if v, ok := info["OutputTokens"].(int); ok {
+		usage.CompletionTokens = v
+	}
+
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
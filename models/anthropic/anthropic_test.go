@@ -0,0 +1,50 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/j0lvera/wise/models"
+)
+
+const thinkingResponse = `{
+	"id": "msg_1",
+	"type": "message",
+	"role": "assistant",
+	"model": "test-model",
+	"content": [
+		{"type": "thinking", "thinking": "Maybe:\n` + "```bash\\nrm -rf build\\n```" + `", "signature": "sig"},
+		{"type": "text", "text": "<thinking>Or ` + "```bash\\nrm -rf /tmp/x\\n```" + `</thinking>\n` + "```bash\\nls\\n```" + `"}
+	],
+	"stop_reason": "end_turn",
+	"usage": {"input_tokens": 10, "output_tokens": 5}
+}`
+
+func TestQueryKeepsThinkingOutOfResponse(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(thinkingResponse))
+	}))
+	defer server.Close()
+
+	m, err := New("test-model", NewConfig().WithAPIKey("test").WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	response, usage, err := m.Query(context.Background(), []models.Message{
+		{Role: "system", Content: "You run shell commands."},
+		{Role: "user", Content: "list the files"},
+	})
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if want := "```bash\nls\n```"; response != want {
+		t.Errorf("Query() = %q, want %q without the thinking", response, want)
+	}
+	if usage.TotalTokens != 15 {
+		t.Errorf("usage = %+v, want 15 total tokens", usage)
+	}
+}
@@ -0,0 +1,32 @@
+package anthropic
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/j0lvera/wise/models/internal/probe"
+)
+
+// defaultBaseURL is used when no base URL is configured.
+const defaultBaseURL = "https://api.anthropic.com/v1"
+
+// Validate checks that the API key works and the model exists.
+func (m *model) Validate(ctx context.Context) error {
+	baseURL := m.cfg.baseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	header := http.Header{
+		"X-Api-Key":         {m.cfg.apiKey},
+		"Anthropic-Version": {"2023-06-01"},
+	}
+	endpoint := strings.TrimSuffix(baseURL, "/") + "/models/" + url.PathEscape(m.name)
+	code, _, err := probe.Do(ctx, http.MethodGet, endpoint, header, nil)
+	if err != nil {
+		return err
+	}
+	return probe.Status(code, m.name)
+}
@@ -0,0 +1,35 @@
+package models
+
+import "context"
+
+// fallback tries a chain of models in order.
+type fallback struct {
+	models []Model
+}
+
+// Fallback returns a Model that queries primary and, if it fails, each
+// secondary in order. The last error is returned if all fail. Context
+// cancellation stops the chain instead of falling back.
+//
+// The chain doesn't report a model name, so per-model pricing isn't
+// applied to it.
+func Fallback(primary Model, secondary ...Model) Model {
+	return &fallback{models: append([]Model{primary}, secondary...)}
+}
+
+// Query implements Model.
+func (f *fallback) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	var err error
+	for _, m := range f.models {
+		var response string
+		var usage TokenUsage
+		response, usage, err = m.Query(ctx, messages)
+		if err == nil {
+			return response, usage, nil
+		}
+		if ctx.Err() != nil {
+			return "", TokenUsage{}, err
+		}
+	}
+	return "", TokenUsage{}, err
+}
@@ -0,0 +1,47 @@
+// Package apikey resolves provider API keys from the environment.
+package apikey
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// cmdTimeout bounds how long a key command may run.
+const cmdTimeout = 30 * time.Second
+
+// FromEnv returns the key in the variable name, else the contents of the
+// file named by name_FILE, else the stdout of the command in name_CMD,
+// run with sh -c. Trailing newlines are trimmed. It returns "" if none
+// is set.
+func FromEnv(name string) (string, error) {
+	if key := os.Getenv(name); key != "" {
+		return key, nil
+	}
+
+	if path := os.Getenv(name + "_FILE"); path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read %s_FILE: %w", name, err)
+		}
+		return strings.TrimRight(string(b), "\r\n"), nil
+	}
+
+	if command := os.Getenv(name + "_CMD"); command != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), cmdTimeout)
+		defer cancel()
+
+		cmd := exec.CommandContext(ctx, "sh", "-c", command)
+		cmd.Stderr = os.Stderr
+		out, err := cmd.Output()
+		if err != nil {
+			return "", fmt.Errorf("failed to run %s_CMD: %w", name, err)
+		}
+		return strings.TrimRight(string(out), "\r\n"), nil
+	}
+
+	return "", nil
+}
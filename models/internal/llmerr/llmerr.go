@@ -0,0 +1,28 @@
+// Package llmerr classifies errors returned by langchaingo clients.
+package llmerr
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/j0lvera/wise/models"
+)
+
+// statusCodeRegex matches the status code in langchaingo's API errors.
+var statusCodeRegex = regexp.MustCompile(`status code: (\d{3})`)
+
+// Classify wraps a client error in a models.QueryError.
+// langchaingo only reports HTTP status and network failures in the
+// error message, so they are recovered from the text.
+func Classify(err error) error {
+	msg := err.Error()
+	qErr := &models.QueryError{
+		Network: strings.Contains(msg, "network error") || strings.Contains(msg, "request timeout: network"),
+		Err:     err,
+	}
+	if m := statusCodeRegex.FindStringSubmatch(msg); m != nil {
+		qErr.StatusCode, _ = strconv.Atoi(m[1])
+	}
+	return qErr
+}
@@ -0,0 +1,46 @@
+// Package probe checks provider endpoints before a run.
+package probe
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Do sends a request and returns the status code and body, which is
+// capped since only small metadata responses are expected.
+func Do(ctx context.Context, method, url string, header http.Header, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid request: %w", err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, nil, fmt.Errorf("provider unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 8<<20))
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, b, nil
+}
+
+// Status turns a failed status code into an error naming the likely cause.
+func Status(code int, model string) error {
+	switch {
+	case code == http.StatusUnauthorized || code == http.StatusForbidden:
+		return fmt.Errorf("API key rejected (HTTP %d)", code)
+	case code == http.StatusNotFound:
+		return fmt.Errorf("model %q not found", model)
+	case code >= 300:
+		return fmt.Errorf("provider returned HTTP %d", code)
+	}
+	return nil
+}
@@ -1,11 +1,15 @@
 package models
 
-import "context"
+import (
+	"context"
+	"net/http"
+)
 
-// Message represents a chat message.
+// Message represents a chat message. It marshals to the
+// {"role": ..., "content": ...} shape of chat completion APIs.
 type Message struct {
-	Role    string
-	Content string
+	Role    string `json:"role"`
+	Content string `json:"content"`
 }
 
 // TokenUsage holds token counts from a model query.
@@ -19,3 +23,53 @@ type TokenUsage struct {
 type Model interface {
 	Query(ctx context.Context, messages []Message) (string, TokenUsage, error)
 }
+
+// Named is implemented by models that report their model name,
+// used for looking up pricing.
+type Named interface {
+	Name() string
+}
+
+// Validator is implemented by models that can check, before a run, that
+// the model exists and the credentials work.
+type Validator interface {
+	Validate(ctx context.Context) error
+}
+
+// StreamingModel is a Model that can deliver response chunks as they arrive.
+// The returned response is the full completion, identical to Query's.
+type StreamingModel interface {
+	Model
+	QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error) (string, TokenUsage, error)
+}
+
+// QueryError describes a failed model query.
+type QueryError struct {
+	StatusCode int  // HTTP status code, 0 if unknown
+	Network    bool // Request failed to reach the provider
+	Err        error
+}
+
+func (e *QueryError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
+// Temporary reports whether the query may succeed if retried:
+// network errors and HTTP 429, 500, 502 and 503.
+func (e *QueryError) Temporary() bool {
+	if e.Network {
+		return true
+	}
+	switch e.StatusCode {
+	case http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package models
+
+import "context"
+
+// multiplexer queries several models at once.
+type multiplexer struct {
+	models []Model
+}
+
+// reply is one model's answer to a multiplexed query.
+type reply struct {
+	response string
+	usage    TokenUsage
+	err      error
+}
+
+// Multiplexer returns a Model that sends each query to first and every
+// model in rest in parallel and returns the first successful response,
+// cancelling the rest. The last error is returned if all fail.
+//
+// Reported usage sums the winner and the models that failed before it.
+// Models cancelled once a winner answers aren't counted, since aborted
+// requests don't report usage. The multiplexer doesn't report a model
+// name, so per-model pricing isn't applied to it.
+func Multiplexer(first Model, rest ...Model) Model {
+	return &multiplexer{models: append([]Model{first}, rest...)}
+}
+
+// Query implements Model.
+func (m *multiplexer) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	replies := make(chan reply, len(m.models))
+	for _, model := range m.models {
+		go func() {
+			response, usage, err := model.Query(ctx, messages)
+			replies <- reply{response, usage, err}
+		}()
+	}
+
+	var total TokenUsage
+	var err error
+	for range m.models {
+		r := <-replies
+		total.PromptTokens += r.usage.PromptTokens
+		total.CompletionTokens += r.usage.CompletionTokens
+		total.TotalTokens += r.usage.TotalTokens
+		if r.err == nil {
+			return r.response, total, nil
+		}
+		err = r.err
+	}
+	return "", total, err
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// stubModel answers after ready is closed, or immediately if it is nil.
+type stubModel struct {
+	response string
+	usage    TokenUsage
+	err      error
+	ready    chan struct{}
+}
+
+func (m stubModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	if m.ready != nil {
+		select {
+		case <-m.ready:
+		case <-ctx.Done():
+			return "", TokenUsage{}, ctx.Err()
+		}
+	}
+	return m.response, m.usage, m.err
+}
+
+func TestMultiplexerSumsUsageOfFailuresBeforeWinner(t *testing.T) {
+	ready := make(chan struct{})
+	failing := stubModel{err: errors.New("overloaded"), usage: TokenUsage{PromptTokens: 10, TotalTokens: 10}}
+	winner := stubModel{response: "ok", usage: TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, ready: ready}
+	slow := stubModel{response: "late", ready: make(chan struct{})}
+
+	m := Multiplexer(failing, winner, slow)
+	go func() {
+		// Let the failure arrive first
+		time.Sleep(50 * time.Millisecond)
+		close(ready)
+	}()
+	response, usage, err := m.Query(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+	if response != "ok" {
+		t.Errorf("response = %q, want ok", response)
+	}
+	want := TokenUsage{PromptTokens: 20, CompletionTokens: 5, TotalTokens: 25}
+	if usage != want {
+		t.Errorf("usage = %+v, want %+v", usage, want)
+	}
+}
+
+func TestMultiplexerReturnsLastErrorWhenAllFail(t *testing.T) {
+	errA, errB := errors.New("a"), errors.New("b")
+	m := Multiplexer(stubModel{err: errA}, stubModel{err: errB})
+
+	_, _, err := m.Query(context.Background(), nil)
+	if !errors.Is(err, errA) && !errors.Is(err, errB) {
+		t.Errorf("Query() error = %v, want one of the model errors", err)
+	}
+}
@@ -0,0 +1,136 @@
+package ollama
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/j0lvera/wise/models"
+	"github.com/j0lvera/wise/models/internal/llmerr"
+
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/ollama"
+)
+
+// DefaultHost is the address of a local Ollama server.
+const DefaultHost = "http://localhost:11434"
+
+// Config holds the model configuration.
+type Config struct {
+	host string
+}
+
+// NewConfig creates a new Config with defaults.
+func NewConfig() Config {
+	return Config{}
+}
+
+// WithHost sets the Ollama server URL.
+func (c Config) WithHost(host string) Config {
+	c.host = host
+	return c
+}
+
+// model implements the Model interface (unexported).
+type model struct {
+	cfg    Config
+	name   string
+	client llms.Model
+}
+
+// New creates a new Ollama model.
+// Falls back to the OLLAMA_HOST env var, then DefaultHost, when not set via builder.
+func New(modelName string, cfg Config) (models.Model, error) {
+	if cfg.host == "" {
+		cfg.host = os.Getenv("OLLAMA_HOST")
+	}
+	if cfg.host == "" {
+		cfg.host = DefaultHost
+	}
+
+	client, err := ollama.New(
+		ollama.WithServerURL(cfg.host),
+		ollama.WithModel(modelName),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LLM client: %w", err)
+	}
+
+	return &model{cfg: cfg, name: modelName, client: client}, nil
+}
+
+// Name returns the model name.
+func (m *model) Name() string {
+	return m.name
+}
+
+// Query sends messages to the LLM and returns the response with token usage.
+func (m *model) Query(ctx context.Context, messages []models.Message) (string, models.TokenUsage, error) {
+	return m.generate(ctx, messages)
+}
+
+// QueryStream sends messages to the LLM, calling onChunk for each streamed
+// chunk, and returns the full response with token usage.
+func (m *model) QueryStream(ctx context.Context, messages []models.Message, onChunk func(chunk string) error) (string, models.TokenUsage, error) {
+	return m.generate(ctx, messages, llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		return onChunk(string(chunk))
+	}))
+}
+
+// generate converts messages and calls the underlying client.
+func (m *model) generate(ctx context.Context, messages []models.Message, opts ...llms.CallOption) (string, models.TokenUsage, error) {
+	llmMessages := make([]llms.MessageContent, 0, len(messages))
+
+	for _, msg := range messages {
+		var msgType llms.ChatMessageType
+		switch msg.Role {
+		case "system":
+			msgType = llms.ChatMessageTypeSystem
+		case "user":
+			msgType = llms.ChatMessageTypeHuman
+		case "assistant":
+			msgType = llms.ChatMessageTypeAI
+		default:
+			continue
+		}
+		llmMessages = append(llmMessages, llms.TextParts(msgType, msg.Content))
+	}
+
+	resp, err := m.client.GenerateContent(ctx, llmMessages, opts...)
+	if err != nil {
+		// Local models are slow; surface deadline and cancellation as-is
+		// so the agent's step timeout is recognized
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", models.TokenUsage{}, fmt.Errorf("failed to generate content: %w", ctxErr)
+		}
+		return "", models.TokenUsage{}, llmerr.Classify(fmt.Errorf("failed to generate content: %w", err))
+	}
+
+	if len(resp.Choices) == 0 {
+		return "", models.TokenUsage{}, fmt.Errorf("no choices returned from model")
+	}
+
+	usage := extractTokenUsage(resp.Choices[0])
+
+	return resp.Choices[0].Content, usage, nil
+}
+
+// extractTokenUsage pulls token counts from langchaingo's GenerationInfo map.
+func extractTokenUsage(choice *llms.ContentChoice) models.TokenUsage {
+	if choice.GenerationInfo == nil {
+		return models.TokenUsage{}
+	}
+
+	info := choice.GenerationInfo
+	usage := models.TokenUsage{}
+
+	if v, ok := info["PromptTokens"].(int); ok {
+		usage.PromptTokens = v
+	}
+	if v, ok := info["CompletionTokens"].(int); ok {
+		usage.CompletionTokens = v
+	}
+
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	return usage
+}
@@ -0,0 +1,26 @@
+package ollama
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/j0lvera/wise/models/internal/probe"
+)
+
+// Validate checks that the server is reachable and has pulled the model.
+func (m *model) Validate(ctx context.Context) error {
+	body, err := json.Marshal(map[string]string{"model": m.name})
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	code, _, err := probe.Do(ctx, http.MethodPost, strings.TrimSuffix(m.cfg.host, "/")+"/api/show", header, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	return probe.Status(code, m.name)
+}
@@ -3,9 +3,13 @@ package openai
 import (
 	"context"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 
 	"github.com/j0lvera/wise/models"
+	"github.com/j0lvera/wise/models/internal/apikey"
+	"github.com/j0lvera/wise/models/internal/llmerr"
 
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/llms/openai"
@@ -13,8 +17,16 @@ import (
 
 // Config holds the model configuration.
 type Config struct {
-	apiKey  string
-	baseURL string
+	apiKey      string
+	baseURL     string
+	toolCalling bool
+	temperature *float64
+	topP        *float64
+	seed        *int
+	headers     map[string]string
+	stop        []string
+	effort      string
+	caching     bool
 }
 
 // NewConfig creates a new Config with defaults.
@@ -34,6 +46,109 @@ func (c Config) WithBaseURL(url string) Config {
 	return c
 }
 
+// WithToolCalling advertises a native run_bash tool to the model.
+// Tool calls are returned encoded with models.EncodeToolCalls; pair
+// with wise.NewToolCallParser to read them. When the history is sent
+// back, encoded calls and the observations after them are restored as
+// tool calls and tool results tied to the call IDs.
+func (c Config) WithToolCalling(enabled bool) Config {
+	c.toolCalling = enabled
+	return c
+}
+
+// WithTemperature sets the sampling temperature. Values near zero make
+// command generation more deterministic. Reasoning models (o1, o3, GPT-5)
+// ignore it.
+func (c Config) WithTemperature(t float64) Config {
+	c.temperature = &t
+	return c
+}
+
+// WithTopP sets nucleus sampling. It is sent as top_p, which langchaingo
+// doesn't forward. If the provider rejects the parameter for a model,
+// requests are retried without it.
+func (c Config) WithTopP(p float64) Config {
+	c.topP = &p
+	return c
+}
+
+// WithSeed requests reproducible sampling. Providers treat it as best
+// effort and many OpenAI-compatible servers ignore it; a seed of 0 is
+// not sent.
+func (c Config) WithSeed(seed int) Config {
+	c.seed = &seed
+	return c
+}
+
+// WithReasoningEffort asks reasoning models to think less or more, e.g.
+// "low", "medium" or "high", trading latency for quality. It is sent as
+// reasoning_effort, which langchaingo doesn't forward. If the provider
+// rejects the parameter for a model, requests are retried without it.
+func (c Config) WithReasoningEffort(effort string) Config {
+	c.effort = effort
+	return c
+}
+
+// WithPromptCaching marks the system prompt for provider-side caching
+// with an ephemeral cache_control block, as used by Anthropic models on
+// OpenRouter, cutting cost and latency on multi-step runs. Providers
+// that cache automatically ignore it; if one rejects it, requests are
+// retried without it.
+func (c Config) WithPromptCaching(enabled bool) Config {
+	c.caching = enabled
+	return c
+}
+
+// WithStopSequences makes the model stop generating at any of the given
+// sequences. The matched sequence is not included in the response, so a
+// stop on a closing fence leaves the block unterminated.
+func (c Config) WithStopSequences(stop ...string) Config {
+	c.stop = slices.Clone(stop)
+	return c
+}
+
+// callOptions returns the generation options set on the config.
+func (c Config) callOptions() []llms.CallOption {
+	var opts []llms.CallOption
+	if c.temperature != nil {
+		opts = append(opts, llms.WithTemperature(*c.temperature))
+	}
+	if c.seed != nil {
+		opts = append(opts, llms.WithSeed(*c.seed))
+	}
+	if len(c.stop) > 0 {
+		opts = append(opts, llms.WithStopWords(c.stop))
+	}
+	return opts
+}
+
+// WithHeaders adds headers to every request, such as OpenRouter's
+// HTTP-Referer and X-Title attribution headers. They are merged with the
+// client's own headers, which take precedence on conflicts.
+func (c Config) WithHeaders(headers map[string]string) Config {
+	c.headers = maps.Clone(headers)
+	return c
+}
+
+// runBashTool describes the run_bash tool to the model.
+var runBashTool = llms.Tool{
+	Type: "function",
+	Function: &llms.FunctionDefinition{
+		Name:        models.ToolRunBash,
+		Description: "Run a bash command and return its output.",
+		Parameters: map[string]any{
+			"type": "object",
+			"properties": map[string]any{
+				"command": map[string]any{
+					"type":        "string",
+					"description": "The bash command to run",
+				},
+			},
+			"required": []string{"command"},
+		},
+	},
+}
+
 // model implements the Model interface (unexported).
 type model struct {
 	cfg    Config
@@ -43,9 +158,15 @@ type model struct {
 
 // New creates a new OpenAI-compatible model.
 // Falls back to OPENAI_API_KEY and OPENAI_BASE_URL env vars when not set via builder.
+// The key may also come from a file named by OPENAI_API_KEY_FILE or the output
+// of the command in OPENAI_API_KEY_CMD.
 func New(modelName string, cfg Config) (models.Model, error) {
 	if cfg.apiKey == "" {
-		cfg.apiKey = os.Getenv("OPENAI_API_KEY")
+		key, err := apikey.FromEnv("OPENAI_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		cfg.apiKey = key
 	}
 	if cfg.baseURL == "" {
 		cfg.baseURL = os.Getenv("OPENAI_BASE_URL")
@@ -62,6 +183,9 @@ func New(modelName string, cfg Config) (models.Model, error) {
 	if cfg.baseURL != "" {
 		clientOpts = append(clientOpts, openai.WithBaseURL(cfg.baseURL))
 	}
+	if len(cfg.headers) > 0 || cfg.topP != nil || cfg.effort != "" || cfg.caching {
+		clientOpts = append(clientOpts, openai.WithHTTPClient(cfg.httpClient()))
+	}
 
 	client, err := openai.New(clientOpts...)
 	if err != nil {
@@ -71,37 +195,123 @@ func New(modelName string, cfg Config) (models.Model, error) {
 	return &model{cfg: cfg, name: modelName, client: client}, nil
 }
 
+// Name returns the model name.
+func (m *model) Name() string {
+	return m.name
+}
+
 // Query sends messages to the LLM and returns the response with token usage.
 func (m *model) Query(ctx context.Context, messages []models.Message) (string, models.TokenUsage, error) {
-	llmMessages := make([]llms.MessageContent, 0, len(messages))
+	return m.generate(ctx, messages)
+}
 
-	for _, msg := range messages {
-		var msgType llms.ChatMessageType
-		switch msg.Role {
-		case "system":
-			msgType = llms.ChatMessageTypeSystem
-		case "user":
-			msgType = llms.ChatMessageTypeHuman
-		case "assistant":
-			msgType = llms.ChatMessageTypeAI
-		default:
-			continue
-		}
-		llmMessages = append(llmMessages, llms.TextParts(msgType, msg.Content))
+// QueryStream sends messages to the LLM, calling onChunk for each streamed
+// chunk, and returns the full response with token usage.
+func (m *model) QueryStream(ctx context.Context, messages []models.Message, onChunk func(chunk string) error) (string, models.TokenUsage, error) {
+	return m.generate(ctx, messages, llms.WithStreamingFunc(func(_ context.Context, chunk []byte) error {
+		return onChunk(string(chunk))
+	}))
+}
+
+// generate converts messages and calls the underlying client.
+func (m *model) generate(ctx context.Context, messages []models.Message, opts ...llms.CallOption) (string, models.TokenUsage, error) {
+	llmMessages := messageContents(messages)
+
+	opts = append(opts, m.cfg.callOptions()...)
+	if m.cfg.toolCalling {
+		opts = append(opts, llms.WithTools([]llms.Tool{runBashTool}))
 	}
 
-	resp, err := m.client.GenerateContent(ctx, llmMessages)
+	resp, err := m.client.GenerateContent(ctx, llmMessages, opts...)
 	if err != nil {
-		return "", models.TokenUsage{}, fmt.Errorf("failed to generate content: %w", err)
+		return "", models.TokenUsage{}, llmerr.Classify(fmt.Errorf("failed to generate content: %w", err))
 	}
 
 	if len(resp.Choices) == 0 {
 		return "", models.TokenUsage{}, fmt.Errorf("no choices returned from model")
 	}
 
-	usage := extractTokenUsage(resp.Choices[0])
+	choice := resp.Choices[0]
+	usage := extractTokenUsage(choice)
 
-	return resp.Choices[0].Content, usage, nil
+	if len(choice.ToolCalls) > 0 {
+		calls := make([]models.ToolCall, 0, len(choice.ToolCalls))
+		for _, tc := range choice.ToolCalls {
+			if tc.FunctionCall == nil {
+				continue
+			}
+			calls = append(calls, models.ToolCall{
+				ID:        tc.ID,
+				Name:      tc.FunctionCall.Name,
+				Arguments: tc.FunctionCall.Arguments,
+			})
+		}
+		return models.EncodeToolCalls(choice.Content, calls), usage, nil
+	}
+
+	return choice.Content, usage, nil
+}
+
+// toolNotRun is the result sent for tool calls beyond the first, since
+// only one command runs per step.
+const toolNotRun = "Not run: only one tool call is executed per step."
+
+// messageContents converts messages for the client. An assistant message
+// encoding tool calls is sent as those calls, and the user message after
+// it as their tool results, as the tool-calling protocol requires.
+func messageContents(messages []models.Message) []llms.MessageContent {
+	contents := make([]llms.MessageContent, 0, len(messages))
+
+	var pending []models.ToolCall
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			contents = append(contents, llms.TextParts(llms.ChatMessageTypeSystem, msg.Content))
+		case "user":
+			if len(pending) == 0 {
+				contents = append(contents, llms.TextParts(llms.ChatMessageTypeHuman, msg.Content))
+				continue
+			}
+			for i, call := range pending {
+				result := toolNotRun
+				if i == 0 {
+					result = msg.Content
+				}
+				contents = append(contents, llms.MessageContent{
+					Role: llms.ChatMessageTypeTool,
+					Parts: []llms.ContentPart{llms.ToolCallResponse{
+						ToolCallID: call.ID,
+						Name:       call.Name,
+						Content:    result,
+					}},
+				})
+			}
+			pending = nil
+		case "assistant":
+			content, calls, ok := models.DecodeToolCalls(msg.Content)
+			if !ok || slices.ContainsFunc(calls, func(c models.ToolCall) bool { return c.ID == "" }) {
+				contents = append(contents, llms.TextParts(llms.ChatMessageTypeAI, msg.Content))
+				continue
+			}
+			var parts []llms.ContentPart
+			if content != "" {
+				parts = append(parts, llms.TextContent{Text: content})
+			}
+			for _, call := range calls {
+				parts = append(parts, llms.ToolCall{
+					ID:   call.ID,
+					Type: "function",
+					FunctionCall: &llms.FunctionCall{
+						Name:      call.Name,
+						Arguments: call.Arguments,
+					},
+				})
+			}
+			contents = append(contents, llms.MessageContent{Role: llms.ChatMessageTypeAI, Parts: parts})
+			pending = calls
+		}
+	}
+	return contents
 }
 
 // extractTokenUsage pulls token counts from langchaingo's GenerationInfo map.
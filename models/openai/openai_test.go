@@ -0,0 +1,144 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/j0lvera/wise/models"
+)
+
+const completion = `{
+	"id": "chatcmpl-1",
+	"object": "chat.completion",
+	"model": "test-model",
+	"choices": [{"index": 0, "message": {"role": "assistant", "content": "ok"}, "finish_reason": "stop"}],
+	"usage": {"prompt_tokens": 1, "completion_tokens": 1, "total_tokens": 2}
+}`
+
+// recordingServer answers chat completions, recording each request body.
+// The first reject requests are answered with a 400 naming top_p.
+type recordingServer struct {
+	mu     sync.Mutex
+	bodies []map[string]any
+	reject int
+}
+
+func (s *recordingServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, _ := io.ReadAll(r.Body)
+	var body map[string]any
+	json.Unmarshal(raw, &body)
+
+	s.mu.Lock()
+	s.bodies = append(s.bodies, body)
+	reject := len(s.bodies) <= s.reject
+	s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	if reject {
+		w.WriteHeader(http.StatusBadRequest)
+		io.WriteString(w, `{"error": {"message": "Unsupported parameter: 'top_p'", "type": "invalid_request_error"}}`)
+		return
+	}
+	io.WriteString(w, completion)
+}
+
+func query(t *testing.T, url string, cfg Config) {
+	t.Helper()
+	m, err := New("test-model", cfg.WithAPIKey("test").WithBaseURL(url))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	messages := []models.Message{{Role: "user", Content: "hi"}}
+	if _, _, err := m.Query(context.Background(), messages); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+}
+
+func TestWithTopPSendsTopP(t *testing.T) {
+	s := &recordingServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	query(t, server.URL, NewConfig().WithTopP(0.5))
+
+	if len(s.bodies) != 1 {
+		t.Fatalf("got %d requests, want 1", len(s.bodies))
+	}
+	if got := s.bodies[0]["top_p"]; got != 0.5 {
+		t.Errorf("top_p = %v, want 0.5", got)
+	}
+}
+
+func TestWithTopPRetriesWithoutRejectedParameter(t *testing.T) {
+	s := &recordingServer{reject: 1}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	query(t, server.URL, NewConfig().WithTopP(0.5))
+
+	if len(s.bodies) != 2 {
+		t.Fatalf("got %d requests, want 2", len(s.bodies))
+	}
+	if _, ok := s.bodies[0]["top_p"]; !ok {
+		t.Error("first request has no top_p")
+	}
+	if got, ok := s.bodies[1]["top_p"]; ok {
+		t.Errorf("retried request has top_p = %v, want it dropped", got)
+	}
+}
+
+func TestTopPUnsetIsNotSent(t *testing.T) {
+	s := &recordingServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	query(t, server.URL, NewConfig())
+
+	if got, ok := s.bodies[0]["top_p"]; ok {
+		t.Errorf("top_p = %v, want it unset", got)
+	}
+}
+
+func TestToolCallHistoryUsesToolMessages(t *testing.T) {
+	s := &recordingServer{}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	m, err := New("test-model", NewConfig().WithToolCalling(true).WithAPIKey("test").WithBaseURL(server.URL))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	call := models.EncodeToolCalls("", []models.ToolCall{{
+		ID:        "call_1",
+		Name:      models.ToolRunBash,
+		Arguments: `{"command":"ls"}`,
+	}})
+	messages := []models.Message{
+		{Role: "system", Content: "system prompt"},
+		{Role: "user", Content: "list the files"},
+		{Role: "assistant", Content: call},
+		{Role: "user", Content: "main.go"},
+	}
+	if _, _, err := m.Query(context.Background(), messages); err != nil {
+		t.Fatalf("Query() error = %v", err)
+	}
+
+	sent, _ := s.bodies[0]["messages"].([]any)
+	if len(sent) != 4 {
+		t.Fatalf("sent %d messages, want 4: %v", len(sent), sent)
+	}
+	assistant, _ := sent[2].(map[string]any)
+	calls, _ := assistant["tool_calls"].([]any)
+	if len(calls) != 1 || calls[0].(map[string]any)["id"] != "call_1" {
+		t.Errorf("assistant message = %v, want a tool call with id call_1", assistant)
+	}
+	result, _ := sent[3].(map[string]any)
+	if result["role"] != "tool" || result["tool_call_id"] != "call_1" || result["content"] != "main.go" {
+		t.Errorf("result message = %v, want a tool result for call_1", result)
+	}
+}
@@ -0,0 +1,171 @@
+package openai
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// transport adds the configured headers and request body fields to every
+// request. Headers the client already set, such as Authorization and
+// Content-Type, are kept.
+type transport struct {
+	headers map[string]string
+	edits   []*bodyEdit
+	base    http.RoundTripper
+}
+
+// bodyEdit changes a JSON request body for an optional provider feature.
+// Once the provider rejects a request naming field, the edit is disabled
+// and later requests are sent without it.
+type bodyEdit struct {
+	field    string
+	apply    func(body map[string]json.RawMessage) error
+	disabled atomic.Bool
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		if req.Header.Get(k) == "" {
+			req.Header.Set(k, v)
+		}
+	}
+
+	if len(t.edits) == 0 || req.Method != http.MethodPost || req.Body == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		var active []*bodyEdit
+		for _, e := range t.edits {
+			if !e.disabled.Load() {
+				active = append(active, e)
+			}
+		}
+		edited, ok := editBody(body, active)
+		if !ok {
+			return t.base.RoundTrip(withBody(req, body))
+		}
+
+		resp, err := t.base.RoundTrip(withBody(req, edited))
+		if err != nil || resp.StatusCode != http.StatusBadRequest {
+			return resp, err
+		}
+
+		// Models without the feature reject the field; retry without it
+		errBody, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		rejected := false
+		for _, e := range active {
+			if strings.Contains(string(errBody), e.field) {
+				e.disabled.Store(true)
+				rejected = true
+			}
+		}
+		if !rejected {
+			resp.Body = io.NopCloser(bytes.NewReader(errBody))
+			return resp, nil
+		}
+	}
+}
+
+// editBody applies edits to a JSON object body. It reports false if
+// there is nothing to apply or the body can't be edited.
+func editBody(body []byte, edits []*bodyEdit) ([]byte, bool) {
+	if len(edits) == 0 {
+		return nil, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, false
+	}
+	for _, e := range edits {
+		if err := e.apply(fields); err != nil {
+			return nil, false
+		}
+	}
+	out, err := json.Marshal(fields)
+	return out, err == nil
+}
+
+// setField returns an edit setting a top-level field.
+func setField(key string, value any) *bodyEdit {
+	return &bodyEdit{
+		field: key,
+		apply: func(body map[string]json.RawMessage) error {
+			v, err := json.Marshal(value)
+			body[key] = v
+			return err
+		},
+	}
+}
+
+// cacheSystemPrompt returns an edit marking the system message for
+// provider-side prompt caching with an ephemeral cache_control block.
+func cacheSystemPrompt() *bodyEdit {
+	return &bodyEdit{
+		field: "cache_control",
+		apply: func(body map[string]json.RawMessage) error {
+			var messages []map[string]any
+			if err := json.Unmarshal(body["messages"], &messages); err != nil {
+				return err
+			}
+			for _, m := range messages {
+				text, ok := m["content"].(string)
+				if m["role"] != "system" || !ok {
+					continue
+				}
+				m["content"] = []map[string]any{{
+					"type":          "text",
+					"text":          text,
+					"cache_control": map[string]string{"type": "ephemeral"},
+				}}
+				break
+			}
+			v, err := json.Marshal(messages)
+			body["messages"] = v
+			return err
+		},
+	}
+}
+
+// withBody returns a copy of req sending body.
+func withBody(req *http.Request, body []byte) *http.Request {
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return req
+}
+
+// httpClient returns a client that applies the config to each request.
+func (c Config) httpClient() *http.Client {
+	t := &transport{
+		headers: maps.Clone(c.headers),
+		base:    http.DefaultTransport,
+	}
+	if c.topP != nil {
+		t.edits = append(t.edits, setField("top_p", *c.topP))
+	}
+	if c.effort != "" {
+		t.edits = append(t.edits, setField("reasoning_effort", c.effort))
+	}
+	if c.caching {
+		t.edits = append(t.edits, cacheSystemPrompt())
+	}
+	return &http.Client{Transport: t}
+}
@@ -0,0 +1,51 @@
+package openai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/j0lvera/wise/models/internal/probe"
+)
+
+// defaultBaseURL is used when no base URL is configured.
+const defaultBaseURL = "https://api.openai.com/v1"
+
+// Validate checks that the API key works and the provider lists the model.
+func (m *model) Validate(ctx context.Context) error {
+	baseURL := m.cfg.baseURL
+	if baseURL == "" {
+		baseURL = defaultBaseURL
+	}
+
+	header := http.Header{"Authorization": {"Bearer " + m.cfg.apiKey}}
+	for k, v := range m.cfg.headers {
+		if header.Get(k) == "" {
+			header.Set(k, v)
+		}
+	}
+	code, body, err := probe.Do(ctx, http.MethodGet, strings.TrimSuffix(baseURL, "/")+"/models", header, nil)
+	if err != nil {
+		return err
+	}
+	if err := probe.Status(code, m.name); err != nil {
+		return err
+	}
+
+	var list struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &list); err != nil {
+		return fmt.Errorf("unexpected model list: %w", err)
+	}
+	for _, entry := range list.Data {
+		if entry.ID == m.name {
+			return nil
+		}
+	}
+	return fmt.Errorf("model %q not found", m.name)
+}
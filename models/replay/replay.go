@@ -0,0 +1,136 @@
+// Package replay records model responses and replays them, for
+// deterministic tests of the agent loop without a live LLM.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/j0lvera/wise/models"
+)
+
+// entry is one recorded query, stored as a JSON line.
+type entry struct {
+	Key      string            `json:"key"`
+	Messages []message         `json:"messages"`
+	Response string            `json:"response"`
+	Usage    models.TokenUsage `json:"usage"`
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// key hashes the input messages to identify a query.
+func key(messages []models.Message) string {
+	h := sha256.New()
+	for _, m := range messages {
+		// Length prefixes keep distinct conversations from colliding
+		fmt.Fprintf(h, "%d:%s%d:%s", len(m.Role), m.Role, len(m.Content), m.Content)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recorder captures the queries of an inner model.
+type recorder struct {
+	inner models.Model
+	mu    sync.Mutex
+	w     io.Writer
+}
+
+// Recorder returns a Model that queries inner and writes each successful
+// query and its response to w as a JSON line.
+func Recorder(inner models.Model, w io.Writer) models.Model {
+	return &recorder{inner: inner, w: w}
+}
+
+// Name reports the inner model's name, if it has one.
+func (r *recorder) Name() string {
+	if n, ok := r.inner.(models.Named); ok {
+		return n.Name()
+	}
+	return ""
+}
+
+// Query implements models.Model.
+func (r *recorder) Query(ctx context.Context, messages []models.Message) (string, models.TokenUsage, error) {
+	response, usage, err := r.inner.Query(ctx, messages)
+	if err != nil {
+		return response, usage, err
+	}
+
+	e := entry{Key: key(messages), Response: response, Usage: usage}
+	for _, m := range messages {
+		e.Messages = append(e.Messages, message{Role: m.Role, Content: m.Content})
+	}
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", models.TokenUsage{}, fmt.Errorf("failed to encode recording: %w", err)
+	}
+	b = append(b, '\n')
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(b); err != nil {
+		return "", models.TokenUsage{}, fmt.Errorf("failed to write recording: %w", err)
+	}
+	return response, usage, nil
+}
+
+// player serves recorded responses.
+type player struct {
+	mu        sync.Mutex
+	responses map[string][]entry
+}
+
+// Player reads a recording made by Recorder and returns a Model that
+// answers each query with the response recorded for the same messages.
+// Identical queries are answered in recording order. A query that wasn't
+// recorded fails rather than returning the wrong response.
+func Player(r io.Reader) (models.Model, error) {
+	p := &player{responses: map[string][]entry{}}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid recording at line %d: %w", line, err)
+		}
+		p.responses[e.Key] = append(p.responses[e.Key], e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recording: %w", err)
+	}
+
+	return p, nil
+}
+
+// Query implements models.Model.
+func (p *player) Query(ctx context.Context, messages []models.Message) (string, models.TokenUsage, error) {
+	if err := ctx.Err(); err != nil {
+		return "", models.TokenUsage{}, err
+	}
+
+	k := key(messages)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	queue := p.responses[k]
+	if len(queue) == 0 {
+		return "", models.TokenUsage{}, fmt.Errorf("no recorded response matches this conversation (%d messages, key %s); it differs from the recording", len(messages), k[:12])
+	}
+	p.responses[k] = queue[1:]
+	return queue[0].Response, queue[0].Usage, nil
+}
@@ -0,0 +1,120 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/j0lvera/wise/models"
+)
+
+// sequenceModel answers with its responses in order.
+type sequenceModel struct {
+	responses []string
+	err       error
+	calls     int
+}
+
+func (m *sequenceModel) Name() string { return "sequence" }
+
+func (m *sequenceModel) Query(ctx context.Context, messages []models.Message) (string, models.TokenUsage, error) {
+	if m.err != nil {
+		return "", models.TokenUsage{}, m.err
+	}
+	response := m.responses[m.calls]
+	m.calls++
+	return response, models.TokenUsage{PromptTokens: m.calls, TotalTokens: m.calls}, nil
+}
+
+func conversation(contents ...string) []models.Message {
+	var messages []models.Message
+	for _, c := range contents {
+		messages = append(messages, models.Message{Role: "user", Content: c})
+	}
+	return messages
+}
+
+func TestRecordThenReplay(t *testing.T) {
+	var recording bytes.Buffer
+	rec := Recorder(&sequenceModel{responses: []string{"first", "second", "third"}}, &recording)
+
+	if n, ok := rec.(models.Named); !ok || n.Name() != "sequence" {
+		t.Errorf("Recorder() doesn't report the inner model's name")
+	}
+
+	ctx := context.Background()
+	for _, messages := range [][]models.Message{
+		conversation("hello"),
+		conversation("hello"),
+		conversation("hello", "again"),
+	} {
+		if _, _, err := rec.Query(ctx, messages); err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+	}
+
+	player, err := Player(&recording)
+	if err != nil {
+		t.Fatalf("Player() error = %v", err)
+	}
+
+	// Queries are matched by content, and identical ones in recording order
+	tests := []struct {
+		messages []models.Message
+		want     string
+		usage    int
+	}{
+		{conversation("hello", "again"), "third", 3},
+		{conversation("hello"), "first", 1},
+		{conversation("hello"), "second", 2},
+	}
+	for _, tt := range tests {
+		got, usage, err := player.Query(ctx, tt.messages)
+		if err != nil {
+			t.Fatalf("Query() error = %v", err)
+		}
+		if got != tt.want || usage.TotalTokens != tt.usage {
+			t.Errorf("Query() = %q, %d tokens, want %q, %d tokens", got, usage.TotalTokens, tt.want, tt.usage)
+		}
+	}
+
+	if _, _, err := player.Query(ctx, conversation("hello")); err == nil {
+		t.Error("Query() replayed an exhausted response")
+	}
+}
+
+func TestReplayRejectsUnrecordedConversation(t *testing.T) {
+	var recording bytes.Buffer
+	rec := Recorder(&sequenceModel{responses: []string{"ok"}}, &recording)
+	rec.Query(context.Background(), conversation("ab", "c"))
+
+	player, err := Player(&recording)
+	if err != nil {
+		t.Fatalf("Player() error = %v", err)
+	}
+	// The same text split differently is a different conversation
+	if _, _, err := player.Query(context.Background(), conversation("a", "bc")); err == nil {
+		t.Error("Query() answered a conversation that wasn't recorded")
+	}
+}
+
+func TestRecorderSkipsFailedQueries(t *testing.T) {
+	var recording bytes.Buffer
+	rec := Recorder(&sequenceModel{err: errors.New("overloaded")}, &recording)
+
+	if _, _, err := rec.Query(context.Background(), conversation("hello")); err == nil {
+		t.Fatal("Query() error = nil, want the inner error")
+	}
+	if recording.Len() != 0 {
+		t.Errorf("recording = %q, want nothing for a failed query", recording.String())
+	}
+}
+
+func TestPlayerRejectsInvalidRecording(t *testing.T) {
+	_, err := Player(strings.NewReader("\n{not json}\n"))
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Player() error = %v, want it to name line 2", err)
+	}
+}
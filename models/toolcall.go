@@ -0,0 +1,40 @@
+package models
+
+import "encoding/json"
+
+// ToolRunBash is the name of the native tool advertised for running commands.
+const ToolRunBash = "run_bash"
+
+// ToolCall is a native tool call emitted by the LLM.
+type ToolCall struct {
+	ID        string `json:"id,omitempty"` // Provider call ID, echoed by the tool result
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
+}
+
+// toolCallResponse is the wire shape of a response carrying tool calls.
+type toolCallResponse struct {
+	Content   string     `json:"content"`
+	ToolCalls []ToolCall `json:"tool_calls"`
+}
+
+// EncodeToolCalls encodes a response with tool calls as a JSON string,
+// so it can travel through Model.Query and be read by a parser. The
+// encoded response is stored in the history as the assistant's message,
+// and the next user message holds the result. Models that advertise tools
+// must turn that pair back into a tool call and a tool result tied to
+// the call's ID before sending the history to the provider.
+func EncodeToolCalls(content string, calls []ToolCall) string {
+	b, _ := json.Marshal(toolCallResponse{Content: content, ToolCalls: calls})
+	return string(b)
+}
+
+// DecodeToolCalls reverses EncodeToolCalls. ok is false if the response
+// does not carry tool calls.
+func DecodeToolCalls(response string) (content string, calls []ToolCall, ok bool) {
+	var r toolCallResponse
+	if err := json.Unmarshal([]byte(response), &r); err != nil || len(r.ToolCalls) == 0 {
+		return "", nil, false
+	}
+	return r.Content, r.ToolCalls, true
+}
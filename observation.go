@@ -0,0 +1,142 @@
+package wise
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ObservationTemplates sets the phrasing of command observations fed
+// back to the model. Empty fields keep the defaults.
+type ObservationTemplates struct {
+	NoOutput  string // Observation for a successful command without output
+	ExitCode  string // Prefix for failed commands, formatted with the exit code as %d
+	Truncated string // Marker for truncated output, formatted with the omitted bytes and lines as %d
+	Unchanged string // Repeated output with deduplication, formatted with the earlier step as %d
+}
+
+// DefaultObservationTemplates are the observation strings used by default.
+var DefaultObservationTemplates = ObservationTemplates{
+	NoOutput:  "(no output)",
+	ExitCode:  "[exit code: %d]\n",
+	Truncated: "\n\n[... %d bytes, %d lines truncated ...]\n\n",
+	Unchanged: "(output unchanged from step %d)",
+}
+
+// withDefaults fills empty fields from DefaultObservationTemplates and
+// checks that ExitCode and Unchanged format cleanly.
+func (t ObservationTemplates) withDefaults() (ObservationTemplates, error) {
+	if t.NoOutput == "" {
+		t.NoOutput = DefaultObservationTemplates.NoOutput
+	}
+	if t.ExitCode == "" {
+		t.ExitCode = DefaultObservationTemplates.ExitCode
+	}
+	if t.Truncated == "" {
+		t.Truncated = DefaultObservationTemplates.Truncated
+	}
+	if t.Unchanged == "" {
+		t.Unchanged = DefaultObservationTemplates.Unchanged
+	}
+	for _, tmpl := range []string{t.ExitCode, t.Unchanged} {
+		if strings.Contains(fmt.Sprintf(tmpl, 1), "%!") {
+			return t, fmt.Errorf("invalid observation template %q: expected a single %%d", tmpl)
+		}
+	}
+	if strings.Contains(fmt.Sprintf(t.Truncated, 1, 1), "%!") {
+		return t, fmt.Errorf("invalid observation template %q: expected two %%d", t.Truncated)
+	}
+	return t, nil
+}
+
+// truncateMiddle keeps about maxLen bytes of s, split between its head
+// and tail at line boundaries, with a marker for what was left out. A
+// head or tail without a line break is cut at a rune boundary instead.
+func truncateMiddle(s string, maxLen int, marker string) string {
+	head := s[:maxLen/2]
+	if i := strings.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	} else {
+		for len(head) > 0 && !utf8.RuneStart(s[len(head)]) {
+			head = head[:len(head)-1]
+		}
+	}
+
+	tail := s[len(s)-maxLen/2:]
+	if i := strings.IndexByte(tail, '\n'); i >= 0 {
+		tail = tail[i+1:]
+	} else {
+		for len(tail) > 0 && !utf8.RuneStart(tail[0]) {
+			tail = tail[1:]
+		}
+	}
+
+	omitted := s[len(head) : len(s)-len(tail)]
+	return strings.TrimSuffix(head, "\n") + fmt.Sprintf(marker, len(omitted), strings.Count(omitted, "\n")) + tail
+}
+
+// observationKey hashes stdout with trailing whitespace removed from
+// each line, together with the exit code.
+func observationKey(output Output) [sha256.Size]byte {
+	lines := strings.Split(strings.TrimSpace(output.Stdout), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return sha256.Sum256(fmt.Appendf(nil, "%d\x00%s", output.ExitCode, strings.Join(lines, "\n")))
+}
+
+// seenObservation reports the step that first produced the same output,
+// recording the current step if the output is new.
+func (a *baseAgent) seenObservation(output Output) (int, bool) {
+	key := observationKey(output)
+	if step, ok := a.seenObs[key]; ok {
+		return step, true
+	}
+	if a.seenObs == nil {
+		a.seenObs = make(map[[sha256.Size]byte]int)
+	}
+	a.seenObs[key] = a.lastStep
+	return 0, false
+}
+
+// binarySample is how much output is inspected to detect binary data.
+const binarySample = 8 << 10
+
+// sanitizeOutput replaces binary stdout or stderr with a short summary and
+// repairs invalid UTF-8 in text, which would otherwise corrupt model
+// requests.
+func sanitizeOutput(output Output) Output {
+	output.Stdout = sanitizeText(output.Stdout)
+	output.Stderr = sanitizeText(output.Stderr)
+	return output
+}
+
+// sanitizeText treats s as binary if its start contains a NUL byte or
+// mostly invalid UTF-8; otherwise invalid sequences become U+FFFD.
+func sanitizeText(s string) string {
+	sample := s[:min(len(s), binarySample)]
+	if utf8.ValidString(s) && !strings.ContainsRune(sample, 0) {
+		return s
+	}
+
+	invalid := 0
+	for i := 0; i < len(sample); {
+		r, size := utf8.DecodeRuneInString(sample[i:])
+		if r == 0 || (r == utf8.RuneError && size == 1) {
+			invalid++
+		}
+		i += size
+	}
+	if strings.ContainsRune(sample, 0) || invalid*10 > len(sample)*3 {
+		return fmt.Sprintf("(binary output, %d bytes, not shown)", len(s))
+	}
+	return strings.ToValidUTF8(s, "\uFFFD")
+}
+
+// sanitizeMessage makes an error message that embeds the raw command
+// output safe to send to the model, swapping in the sanitized output.
+func sanitizeMessage(msg string, raw, clean Output) string {
+	msg = strings.Replace(msg, raw.String(), clean.String(), 1)
+	return strings.ToValidUTF8(strings.ReplaceAll(msg, "\x00", ""), "\uFFFD")
+}
@@ -1,32 +1,357 @@
 package wise
 
 import (
+	"encoding/json"
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/j0lvera/wise/executor"
 	"github.com/j0lvera/wise/executor/local"
+	"github.com/j0lvera/wise/models"
 )
 
 // commandRegex is compiled once at package level for performance.
 var commandRegex = regexp.MustCompile("(?s)```bash\\s*\\n(.*?)\\n```")
 
+// writeFenceRegex matches the opening line of a ```write path=<path>
+// block, of three or more backticks. The path may be double-quoted to
+// include spaces.
+var writeFenceRegex = regexp.MustCompile("^(```+)write[ \t]+path=(\"(?:[^\"\\\\]|\\\\.)*\"|\\S+)")
+
+// waitFenceRegex matches a ```wait 30s``` block, on one line or with the
+// closing fence on the next.
+var waitFenceRegex = regexp.MustCompile("```wait[ \\t]+([^\\s`]+)[ \\t]*\\n?```")
+
+// inlineCodeRegex matches single-backtick code spans.
+var inlineCodeRegex = regexp.MustCompile("(?:^|[^`])`([^`\n]+)`(?:[^`]|$)")
+
+// timeoutDirective matches a "# timeout: 120s" comment on the first line
+// of a command.
+var timeoutDirective = regexp.MustCompile(`^#\s*timeout:\s*(\S+)\s*$`)
+
+// bashAction builds a bash action, honoring a leading timeout directive.
+// The directive stays in the command, where bash treats it as a comment.
+func bashAction(command string) (Action, error) {
+	action := Action{
+		Type:    local.ActionTypeBash,
+		Command: command,
+	}
+
+	first, _, _ := strings.Cut(command, "\n")
+	if m := timeoutDirective.FindStringSubmatch(strings.TrimSpace(first)); m != nil {
+		d, err := time.ParseDuration(m[1])
+		if err != nil || d <= 0 {
+			return Action{}, &ProcessErr{
+				Type:    ProcessErrFormat,
+				Message: fmt.Sprintf("Invalid timeout directive %q. Use a positive duration such as \"# timeout: 120s\".", m[1]),
+			}
+		}
+		action.Timeout = d
+	}
+
+	return action, nil
+}
+
 // BashParser extracts bash commands from markdown code blocks.
-type BashParser struct{}
+type BashParser struct {
+	lenient  bool
+	inline   bool
+	condense bool
+	writes   bool
+	waits    bool
+}
+
+// block is a command found in a response.
+type block struct {
+	start int    // Offset of the opening fence or backtick
+	end   int    // Offset just past the closing fence of a write block
+	body  string // Command, file content for write blocks or duration for wait blocks
+	path  string // Target file of a write block
+	fence string // Opening fence of a write block
+	write bool
+	wait  bool
+}
 
 // NewBashParser creates a new bash command parser.
 func NewBashParser() *BashParser {
 	return &BashParser{}
 }
 
+// WithLenientFences also accepts sh, shell and unlabeled code fences,
+// which smaller models often emit instead of bash.
+func (p *BashParser) WithLenientFences(enabled bool) *BashParser {
+	c := *p
+	c.lenient = enabled
+	return &c
+}
+
+// WithInlineFallback accepts a single inline `command` when the response
+// has no fenced block. Fenced blocks always take priority.
+func (p *BashParser) WithInlineFallback(enabled bool) *BashParser {
+	c := *p
+	c.inline = enabled
+	return &c
+}
+
+// WithWriteBlocks also accepts ```write path=<path> blocks, whose body
+// is written to the file as is, without shell quoting. They produce
+// write_file actions, which the local environment supports; describe the
+// format in the system prompt. Paths with spaces are double-quoted.
+func (p *BashParser) WithWriteBlocks(enabled bool) *BashParser {
+	c := *p
+	c.writes = enabled
+	return &c
+}
+
+// WithWaitBlocks also accepts ```wait 30s``` blocks, which pause the run
+// for the duration without running a command, for tasks that poll.
+// Unlike sleep in a command, the wait is cancelled with the run.
+// Describe the format in the system prompt.
+func (p *BashParser) WithWaitBlocks(enabled bool) *BashParser {
+	c := *p
+	c.waits = enabled
+	return &c
+}
+
+// WithCondensedHistory stores only the command blocks of each response
+// in the conversation, dropping the reasoning around them to save tokens
+// on long runs. The reasoning before the first command is reported
+// through Hooks.OnThought.
+func (p *BashParser) WithCondensedHistory(enabled bool) *BashParser {
+	c := *p
+	c.condense = enabled
+	return &c
+}
+
+// SplitThought implements ThoughtSplitter. Without condensed history, or
+// when the response has no command, the response is kept whole.
+func (p *BashParser) SplitThought(response string) (thought, message string) {
+	blocks := p.blocks(response)
+	if !p.condense || len(blocks) == 0 {
+		return "", response
+	}
+
+	// The thought is the text before the first command
+	thought = strings.TrimSpace(response[:blocks[0].start])
+
+	fenced := make([]string, len(blocks))
+	for i, b := range blocks {
+		switch {
+		case b.write:
+			fenced[i] = b.fence + "write path=" + quotePath(b.path) + "\n" + b.body + "\n" + b.fence
+		case b.wait:
+			fenced[i] = "```wait " + b.body + "```"
+		default:
+			fenced[i] = "```bash\n" + strings.TrimSpace(b.body) + "\n```"
+		}
+	}
+	return thought, strings.Join(fenced, "\n\n")
+}
+
+// blocks returns the command blocks in the response, in order.
+func (p *BashParser) blocks(response string) []block {
+	var blocks []block
+	if p.lenient {
+		blocks = shellFences(response)
+	} else {
+		for _, m := range commandRegex.FindAllStringSubmatchIndex(response, -1) {
+			blocks = append(blocks, block{start: m[0], body: response[m[2]:m[3]]})
+		}
+	}
+
+	if p.writes {
+		// Fenced commands inside a file's content aren't commands
+		writes := writeFences(response)
+		blocks = slices.DeleteFunc(blocks, func(b block) bool {
+			return slices.ContainsFunc(writes, func(w block) bool { return b.start > w.start && b.start < w.end })
+		})
+		blocks = append(blocks, writes...)
+	}
+	if p.waits {
+		for _, m := range waitFenceRegex.FindAllStringSubmatchIndex(response, -1) {
+			blocks = append(blocks, block{start: m[0], body: response[m[2]:m[3]], wait: true})
+		}
+	}
+	slices.SortFunc(blocks, func(a, b block) int { return a.start - b.start })
+
+	if len(blocks) == 0 && p.inline {
+		// Several inline spans are more likely file names than commands
+		if spans := inlineCodeRegex.FindAllStringSubmatchIndex(response, -1); len(spans) == 1 {
+			m := spans[0]
+			blocks = append(blocks, block{start: m[2] - 1, body: response[m[2]:m[3]]})
+		}
+	}
+	return blocks
+}
+
+// writeFences returns the ```write path=<path> blocks in the response.
+// A block ends at a line holding only its opening fence. Fenced code in
+// the content is paired first, so Markdown with code blocks is written
+// whole; a longer opening fence, like ````write, lets the content hold
+// unpaired fences.
+func writeFences(response string) []block {
+	var blocks []block
+	var cur *block
+	var body []string
+	depth, offset := 0, 0
+
+	for _, line := range strings.Split(response, "\n") {
+		lineStart := offset
+		offset += len(line) + 1
+		trimmed := strings.TrimSpace(line)
+
+		if cur == nil {
+			m := writeFenceRegex.FindStringSubmatch(trimmed)
+			if m == nil {
+				continue
+			}
+			path := m[2]
+			if unquoted, err := strconv.Unquote(path); err == nil {
+				path = unquoted
+			}
+			cur = &block{start: lineStart, path: path, fence: m[1], write: true}
+			body, depth = nil, 0
+			continue
+		}
+
+		if fence, info, ok := fenceLine(trimmed); ok {
+			// Only a fence longer than three backticks is unambiguous
+			closing := info == "" && fence == cur.fence && (depth == 0 || len(fence) > 3)
+			switch {
+			case closing:
+				cur.end = min(offset, len(response))
+				cur.body = strings.Join(body, "\n")
+				blocks = append(blocks, *cur)
+				cur = nil
+				continue
+			case info != "":
+				depth++
+			case depth > 0:
+				depth--
+			default:
+				depth++
+			}
+		}
+		body = append(body, line)
+	}
+	return blocks
+}
+
+// fenceLine splits a line starting with a code fence into the fence and
+// its info string.
+func fenceLine(line string) (fence, info string, ok bool) {
+	if !strings.HasPrefix(line, "```") {
+		return "", "", false
+	}
+	rest := strings.TrimLeft(line, "`")
+	return line[:len(line)-len(rest)], strings.TrimSpace(rest), true
+}
+
+// quotePath quotes a write block path if it contains spaces or quotes.
+func quotePath(path string) string {
+	if strings.ContainsAny(path, " \t\"") {
+		return strconv.Quote(path)
+	}
+	return path
+}
+
+// action converts the block to an action. n is the block's position for
+// error messages, or 0 when only one block is expected.
+func (b block) action(n int) (Action, error) {
+	where := "bash block"
+	if n > 0 {
+		where = fmt.Sprintf("bash block %d", n)
+	}
+
+	if b.write {
+		if b.path == "" {
+			return Action{}, &ProcessErr{
+				Type:    ProcessErrFormat,
+				Message: "Missing path in write block. Use ```write path=<file>.",
+			}
+		}
+		return Action{
+			Type:    local.ActionTypeWriteFile,
+			Command: b.body + "\n",
+			Path:    b.path,
+		}, nil
+	}
+
+	if b.wait {
+		d, err := time.ParseDuration(b.body)
+		if err != nil || d <= 0 {
+			return Action{}, &ProcessErr{
+				Type:    ProcessErrFormat,
+				Message: fmt.Sprintf("Invalid wait duration %q. Use a positive duration such as ```wait 30s```.", b.body),
+			}
+		}
+		return Action{Type: executor.ActionTypeWait, Command: d.String()}, nil
+	}
+
+	command := strings.TrimSpace(b.body)
+	if command == "" {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Empty command in %s. Please provide a valid command.", where),
+		}
+	}
+	return bashAction(command)
+}
+
+// shellFences returns the contents of bash, sh, shell and unlabeled code
+// fences. Fences are paired line by line, so the closing fence of another
+// language's block is never mistaken for an unlabeled opening fence.
+func shellFences(response string) []block {
+	var blocks []block
+	var body []string
+	inside, accept := false, false
+	start, offset := 0, 0
+
+	for _, line := range strings.Split(response, "\n") {
+		lineStart := offset
+		offset += len(line) + 1
+
+		trimmed := strings.TrimSpace(line)
+		if !inside {
+			if lang, ok := strings.CutPrefix(trimmed, "```"); ok {
+				// A fence closed on the same line, like ```wait 30s```
+				if strings.HasSuffix(lang, "```") {
+					continue
+				}
+				inside, body, start = true, nil, lineStart
+				switch strings.TrimSpace(lang) {
+				case "bash", "sh", "shell", "":
+					accept = true
+				default:
+					accept = false
+				}
+			}
+			continue
+		}
+		if trimmed == "```" {
+			inside = false
+			if accept {
+				blocks = append(blocks, block{start: start, body: strings.Join(body, "\n")})
+			}
+			continue
+		}
+		body = append(body, line)
+	}
+	return blocks
+}
+
 // ParseAction extracts a single bash command from the response.
 func (p *BashParser) ParseAction(response string) (Action, error) {
-	matches := commandRegex.FindAllStringSubmatch(response, -1)
+	matches := p.blocks(response)
 
 	if len(matches) == 0 {
 		return Action{}, &ProcessErr{
 			Type:    ProcessErrFormat,
-			Message: "No bash command found. If the task is complete, respond with TASK_COMPLETE. Otherwise, provide exactly one command in ```bash``` block.",
+			Message: "No bash command found. If the task is complete, run a command that prints " + DefaultCompletionMarker + ". Otherwise, provide exactly one command in ```bash``` block.",
 		}
 	}
 
@@ -37,16 +362,231 @@ func (p *BashParser) ParseAction(response string) (Action, error) {
 		}
 	}
 
-	command := strings.TrimSpace(matches[0][1])
+	return matches[0].action(0)
+}
+
+// MultiBashParser extracts an ordered list of bash commands from
+// markdown code blocks.
+type MultiBashParser struct {
+	single *BashParser
+}
+
+// NewMultiBashParser creates a new multi-command bash parser.
+func NewMultiBashParser() *MultiBashParser {
+	return &MultiBashParser{single: NewBashParser()}
+}
+
+// ParseAction extracts a single bash command, like BashParser.
+func (p *MultiBashParser) ParseAction(response string) (Action, error) {
+	return p.single.ParseAction(response)
+}
+
+// WithLenientFences also accepts sh, shell and unlabeled code fences.
+func (p *MultiBashParser) WithLenientFences(enabled bool) *MultiBashParser {
+	return &MultiBashParser{single: p.single.WithLenientFences(enabled)}
+}
+
+// WithInlineFallback accepts a single inline `command` when the response
+// has no fenced block.
+func (p *MultiBashParser) WithInlineFallback(enabled bool) *MultiBashParser {
+	return &MultiBashParser{single: p.single.WithInlineFallback(enabled)}
+}
+
+// WithWriteBlocks also accepts ```write path=<path> blocks.
+func (p *MultiBashParser) WithWriteBlocks(enabled bool) *MultiBashParser {
+	return &MultiBashParser{single: p.single.WithWriteBlocks(enabled)}
+}
+
+// WithWaitBlocks also accepts ```wait 30s``` blocks.
+func (p *MultiBashParser) WithWaitBlocks(enabled bool) *MultiBashParser {
+	return &MultiBashParser{single: p.single.WithWaitBlocks(enabled)}
+}
+
+// WithCondensedHistory stores only the command blocks of each response
+// in the conversation.
+func (p *MultiBashParser) WithCondensedHistory(enabled bool) *MultiBashParser {
+	return &MultiBashParser{single: p.single.WithCondensedHistory(enabled)}
+}
+
+// SplitThought implements ThoughtSplitter, like BashParser.
+func (p *MultiBashParser) SplitThought(response string) (thought, message string) {
+	return p.single.SplitThought(response)
+}
+
+// ParseActions extracts every bash command from the response, in order.
+func (p *MultiBashParser) ParseActions(response string) ([]Action, error) {
+	matches := p.single.blocks(response)
+
+	if len(matches) == 0 {
+		return nil, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: "No bash command found. If the task is complete, run a command that prints " + DefaultCompletionMarker + ". Otherwise, provide one or more commands in ```bash``` blocks.",
+		}
+	}
+
+	actions := make([]Action, 0, len(matches))
+	for i, m := range matches {
+		action, err := m.action(i + 1)
+		if err != nil {
+			return nil, err
+		}
+		actions = append(actions, action)
+	}
+
+	return actions, nil
+}
+
+// ToolCallParser extracts a command from a native run_bash tool call.
+// Responses without tool calls fall back to the markdown BashParser.
+type ToolCallParser struct {
+	fallback Parser
+}
+
+// NewToolCallParser creates a new tool call parser.
+func NewToolCallParser() *ToolCallParser {
+	return &ToolCallParser{fallback: NewBashParser()}
+}
+
+// ParseAction extracts a single command from the response's tool calls.
+func (p *ToolCallParser) ParseAction(response string) (Action, error) {
+	_, calls, ok := models.DecodeToolCalls(response)
+	if !ok {
+		return p.fallback.ParseAction(response)
+	}
+
+	if len(calls) > 1 {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Found %d tool calls, expected exactly one. Please call %s once.", len(calls), models.ToolRunBash),
+		}
+	}
+
+	call := calls[0]
+	if call.Name != models.ToolRunBash {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Unknown tool %q. Use %s to run commands.", call.Name, models.ToolRunBash),
+		}
+	}
+
+	var args struct {
+		Command string `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(call.Arguments), &args); err != nil {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Invalid %s arguments: %s. Provide a JSON object with a \"command\" string.", models.ToolRunBash, err),
+		}
+	}
+
+	command := strings.TrimSpace(args.Command)
 	if command == "" {
 		return Action{}, &ProcessErr{
 			Type:    ProcessErrFormat,
-			Message: "Empty command in bash block. Please provide a valid command.",
+			Message: "Empty command in tool call. Please provide a valid command.",
 		}
 	}
 
-	return Action{
-		Type:    local.ActionTypeBash,
-		Command: command,
-	}, nil
+	return bashAction(command)
+}
+
+// JSONParser extracts a command from a JSON object such as
+// {"action": "bash", "command": "ls"}. Surrounding prose is tolerated;
+// the first balanced JSON object in the response is used.
+//
+// Pair it with a system prompt that instructs JSON output, e.g.
+// DefaultJSONSystemPrompt:
+//
+//	cfg := wise.NewConfig().
+//		WithParser(wise.NewJSONParser()).
+//		WithSystemPrompt(wise.DefaultJSONSystemPrompt)
+type JSONParser struct{}
+
+// NewJSONParser creates a new JSON action parser.
+func NewJSONParser() *JSONParser {
+	return &JSONParser{}
+}
+
+// ParseAction extracts a single command from the first JSON object.
+func (p *JSONParser) ParseAction(response string) (Action, error) {
+	obj, ok := firstJSONObject(response)
+	if !ok {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: `No JSON object found. Respond with a single object like {"action": "bash", "command": "ls"}.`,
+		}
+	}
+
+	var msg struct {
+		Action  string  `json:"action"`
+		Command *string `json:"command"`
+	}
+	if err := json.Unmarshal([]byte(obj), &msg); err != nil {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf(`Malformed JSON: %s. Respond with a single object like {"action": "bash", "command": "ls"}.`, err),
+		}
+	}
+
+	if msg.Action != "" && msg.Action != local.ActionTypeBash {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf(`Unsupported action %q. Use "action": "bash".`, msg.Action),
+		}
+	}
+
+	if msg.Command == nil {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: `Missing "command" field. Respond with a single object like {"action": "bash", "command": "ls"}.`,
+		}
+	}
+
+	command := strings.TrimSpace(*msg.Command)
+	if command == "" {
+		return Action{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: `Empty "command" field. Please provide a valid command.`,
+		}
+	}
+
+	return bashAction(command)
+}
+
+// firstJSONObject returns the first balanced {...} in s, skipping braces
+// inside JSON strings.
+func firstJSONObject(s string) (string, bool) {
+	start := strings.IndexByte(s, '{')
+	if start < 0 {
+		return "", false
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := start; i < len(s); i++ {
+		c := s[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return s[start : i+1], true
+			}
+		}
+	}
+	return "", false
 }
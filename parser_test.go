@@ -0,0 +1,125 @@
+package wise
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/executor/echo"
+)
+
+func TestBashParserHeredoc(t *testing.T) {
+	response := "Writing the file:\n```bash\ncat > notes.txt <<'EOF'\nfirst line\nsecond line\nEOF\n```"
+
+	action, err := NewBashParser().ParseAction(response)
+	if err != nil {
+		t.Fatalf("ParseAction() error = %v", err)
+	}
+	want := "cat > notes.txt <<'EOF'\nfirst line\nsecond line\nEOF"
+	if action.Command != want {
+		t.Errorf("Command = %q, want %q", action.Command, want)
+	}
+}
+
+func TestBashParserWriteBlocks(t *testing.T) {
+	readme := "# Title\n\nRun it:\n\n```bash\nmake build\n```\n\nDone."
+
+	tests := []struct {
+		name     string
+		response string
+		path     string
+		content  string
+	}{
+		{
+			name:     "multi-line content",
+			response: "```write path=notes.txt\nfirst line\n\nthird line\n```",
+			path:     "notes.txt",
+			content:  "first line\n\nthird line\n",
+		},
+		{
+			name:     "quoted path",
+			response: "```write path=\"my notes.txt\"\nhello\n```",
+			path:     "my notes.txt",
+			content:  "hello\n",
+		},
+		{
+			name:     "embedded fence",
+			response: "Adding docs.\n```write path=README.md\n" + readme + "\n```\nThat's it.",
+			path:     "README.md",
+			content:  readme + "\n",
+		},
+		{
+			name:     "longer fence with unpaired inner fence",
+			response: "````write path=snippet.md\nstart\n```\n````",
+			path:     "snippet.md",
+			content:  "start\n```\n",
+		},
+	}
+
+	p := NewBashParser().WithWriteBlocks(true)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			action, err := p.ParseAction(tt.response)
+			if err != nil {
+				t.Fatalf("ParseAction() error = %v", err)
+			}
+			if action.Type != executor.ActionTypeWriteFile {
+				t.Errorf("Type = %q, want %q", action.Type, executor.ActionTypeWriteFile)
+			}
+			if action.Path != tt.path {
+				t.Errorf("Path = %q, want %q", action.Path, tt.path)
+			}
+			if action.Command != tt.content {
+				t.Errorf("content = %q, want %q", action.Command, tt.content)
+			}
+		})
+	}
+}
+
+func TestMultiBashParserWriteBlockWithCommands(t *testing.T) {
+	response := "```write path=README.md\n```bash\nrm -rf build\n```\n```\n\n```bash\ncat README.md\n```"
+
+	actions, err := NewMultiBashParser().WithWriteBlocks(true).ParseActions(response)
+	if err != nil {
+		t.Fatalf("ParseActions() error = %v", err)
+	}
+	if len(actions) != 2 {
+		t.Fatalf("got %d actions, want 2: %+v", len(actions), actions)
+	}
+	if actions[0].Type != executor.ActionTypeWriteFile || actions[0].Command != "```bash\nrm -rf build\n```\n" {
+		t.Errorf("actions[0] = %+v, want the whole README", actions[0])
+	}
+	if actions[1].Command != "cat README.md" {
+		t.Errorf("actions[1].Command = %q, want %q", actions[1].Command, "cat README.md")
+	}
+}
+
+func TestBashParserCondensedWriteBlockKeepsFence(t *testing.T) {
+	response := "Thinking.\n````write path=a.md\n```\n````"
+
+	_, message := NewBashParser().WithWriteBlocks(true).WithCondensedHistory(true).SplitThought(response)
+	if message != "````write path=a.md\n```\n````" {
+		t.Errorf("message = %q", message)
+	}
+}
+
+func TestFormatFeedbackUsesCustomMarker(t *testing.T) {
+	model := &scriptedModel{responses: []string{
+		"I'm done.",
+		"```bash\nALL_DONE\n```",
+	}}
+	a, err := New(model, echo.New(echo.NewConfig()), NewConfig().WithCompletionMarker("ALL_DONE"))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	feedback := a.Messages()[3].Content
+	if !strings.Contains(feedback, "ALL_DONE") || strings.Contains(feedback, DefaultCompletionMarker) {
+		t.Errorf("feedback = %q, want it to name ALL_DONE instead of %s", feedback, DefaultCompletionMarker)
+	}
+}
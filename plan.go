@@ -0,0 +1,47 @@
+package wise
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultPlanPrompt is the system prompt used by Plan.
+const DefaultPlanPrompt = `You are an autonomous agent that completes tasks by running bash commands.
+
+Before running anything, describe your plan for the task below. List the
+commands you intend to run, in order, each with a short explanation of why.
+Note any assumptions and anything that could be destructive or need
+confirmation. Do not claim to have run anything.`
+
+// Plan asks the model for a plan of the commands it would run for task,
+// in a single query, without parsing or executing anything. The
+// conversation is left untouched; token usage is added to Usage.
+func (a *baseAgent) Plan(ctx context.Context, task string) (string, error) {
+	_, user, err := a.renderPrompts(task)
+	if err != nil {
+		return "", err
+	}
+
+	messages := []Message{
+		{Role: RoleSystem, Content: DefaultPlanPrompt},
+		{Role: RoleUser, Content: user},
+	}
+
+	a.modelLog.Debug().Msg("querying model for a plan")
+
+	ctx, span := a.startSpan(ctx, "wise.model.query")
+	start := time.Now()
+	response, usage, err := a.model.Query(ctx, messages)
+	a.cfg.hooks.query(time.Since(start), usage, err)
+	endSpan(span, err)
+	if err != nil {
+		return "", fmt.Errorf("query failed: %w", err)
+	}
+
+	a.totalUsage.PromptTokens += usage.PromptTokens
+	a.totalUsage.CompletionTokens += usage.CompletionTokens
+	a.totalUsage.TotalTokens += usage.TotalTokens
+
+	return response, nil
+}
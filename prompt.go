@@ -0,0 +1,98 @@
+package wise
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// PromptData is the data available to prompt templates.
+type PromptData struct {
+	Task       string            // Task passed to Run
+	WorkingDir string            // Working directory of the process
+	Date       string            // Current date, YYYY-MM-DD
+	OS         string            // Operating system, as runtime.GOOS
+	Env        map[string]string // Allowlisted environment variables
+	Inputs     []string          // Names of inputs set with WithInputs, sorted
+}
+
+// prompts holds the parsed system and user prompt templates.
+type prompts struct {
+	system *template.Template
+	user   *template.Template
+}
+
+// parsePrompts parses the configured prompts as text/template templates.
+func parsePrompts(cfg Config) (prompts, error) {
+	system, err := template.New("system").Option("missingkey=error").Parse(cfg.systemPrompt)
+	if err != nil {
+		return prompts{}, fmt.Errorf("invalid system prompt template: %w", err)
+	}
+
+	var user *template.Template
+	if cfg.userPrompt != "" {
+		user, err = template.New("user").Option("missingkey=error").Parse(cfg.userPrompt)
+		if err != nil {
+			return prompts{}, fmt.Errorf("invalid user prompt template: %w", err)
+		}
+	}
+
+	return prompts{system: system, user: user}, nil
+}
+
+// promptData collects the template data for a task.
+func (a *baseAgent) promptData(task string) PromptData {
+	wd, _ := os.Getwd()
+	env := make(map[string]string, len(a.cfg.promptEnv))
+	for _, name := range a.cfg.promptEnv {
+		env[name] = os.Getenv(name)
+	}
+	return PromptData{
+		Task:       task,
+		WorkingDir: wd,
+		Date:       time.Now().Format(time.DateOnly),
+		OS:         runtime.GOOS,
+		Env:        env,
+		Inputs:     slices.Sorted(maps.Keys(a.cfg.inputs)),
+	}
+}
+
+// renderPrompts returns the system prompt and the user message for task.
+// Without a user prompt template the task is used as is.
+func (a *baseAgent) renderPrompts(task string) (system, user string, err error) {
+	data := a.promptData(task)
+
+	var b strings.Builder
+	if err := a.prompts.system.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("failed to render system prompt: %w", err)
+	}
+	system = b.String()
+	if len(a.cfg.tools) > 0 {
+		system = strings.TrimRight(system, "\n") + "\n\n" + toolsSection(a.cfg.tools)
+	}
+
+	if a.prompts.user == nil {
+		return system, task, nil
+	}
+
+	b.Reset()
+	if err := a.prompts.user.Execute(&b, data); err != nil {
+		return "", "", fmt.Errorf("failed to render user prompt: %w", err)
+	}
+	return system, b.String(), nil
+}
+
+// toolsSection lists the described tools for the system prompt.
+func toolsSection(tools []DescribedActionHandler) string {
+	var b strings.Builder
+	b.WriteString("Available tools:\n")
+	for _, t := range tools {
+		fmt.Fprintf(&b, "- %s: %s\n", t.Name(), strings.TrimSpace(t.Description()))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
@@ -0,0 +1,48 @@
+package wise
+
+import "errors"
+
+// Result describes how the most recent run ended.
+type Result struct {
+	Output  string            // Final output, or partial output if not complete
+	Reason  TerminationReason // Why the run ended
+	Steps   int               // Steps taken in the conversation so far
+	Command string            // Command that printed the completion marker
+	Usage   TokenUsage        // Cumulative token usage
+	Cost    float64           // Cumulative cost in dollars
+	Err     error             // Error returned by the run, nil if complete
+}
+
+// Completed reports whether the task actually completed, as opposed to
+// hitting a limit, being aborted or failing.
+func (r Result) Completed() bool {
+	return r.Reason == ReasonComplete
+}
+
+// newResult records the outcome of a run.
+func (a *baseAgent) newResult(output string, err error) Result {
+	r := Result{
+		Output:  output,
+		Steps:   a.lastStep,
+		Command: a.finalCommand,
+		Usage:   a.totalUsage,
+		Cost:    a.Cost(),
+		Err:     err,
+	}
+
+	var termErr *TerminatingErr
+	switch {
+	case err == nil:
+		r.Reason = ReasonComplete
+	case errors.As(err, &termErr):
+		r.Reason = termErr.Reason
+	default:
+		r.Reason = ReasonError
+	}
+	return r
+}
+
+// Result returns the outcome of the most recent Run, Continue or Chat.
+func (a *baseAgent) Result() Result {
+	return a.result
+}
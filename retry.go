@@ -0,0 +1,67 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"time"
+
+	"github.com/j0lvera/wise/models"
+)
+
+// MaxRetryDelay caps the backoff between query attempts.
+const MaxRetryDelay = time.Minute
+
+// retryNotice is streamed before a retry when the failed attempt had
+// already streamed part of its response.
+const retryNotice = "\n[response interrupted, retrying]\n"
+
+// queryWithRetry queries the model, retrying transient failures with
+// exponential backoff and jitter according to the configured policy.
+func (a *baseAgent) queryWithRetry(ctx context.Context) (string, models.TokenUsage, error) {
+	for attempt := 1; ; attempt++ {
+		var streamed bool
+		response, usage, err := a.query(ctx, &streamed)
+		if err == nil || attempt >= a.cfg.retryAttempts || ctx.Err() != nil || !isRetryable(err) {
+			return response, usage, err
+		}
+		// The retry streams its response from the start
+		if streamed {
+			io.WriteString(a.cfg.output, retryNotice)
+		}
+
+		delay := backoff(a.cfg.retryDelay, attempt)
+		a.modelLog.Warn().
+			Err(err).
+			Int("attempt", attempt).
+			Dur("delay", delay).
+			Msg("query failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return "", models.TokenUsage{}, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// isRetryable reports whether a query error is transient.
+func isRetryable(err error) bool {
+	var qErr *models.QueryError
+	return errors.As(err, &qErr) && qErr.Temporary()
+}
+
+// backoff returns the delay before the next attempt: base doubled for
+// each previous attempt, capped at MaxRetryDelay, plus up to 50% random
+// jitter.
+func backoff(base time.Duration, attempt int) time.Duration {
+	if base <= 0 {
+		return 0
+	}
+	d := min(base, MaxRetryDelay)
+	for i := 1; i < attempt && d < MaxRetryDelay; i++ {
+		d = min(d*2, MaxRetryDelay)
+	}
+	return d + rand.N(d/2+1)
+}
@@ -0,0 +1,68 @@
+package wise
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor/echo"
+	"github.com/j0lvera/wise/models"
+)
+
+func TestBackoffIsCapped(t *testing.T) {
+	if d := backoff(time.Second, 1); d < time.Second || d > 1500*time.Millisecond {
+		t.Errorf("backoff(1s, 1) = %s, want 1s plus up to 50%% jitter", d)
+	}
+	for _, attempt := range []int{10, 40, 64, 100, 1000} {
+		d := backoff(time.Second, attempt)
+		if d < MaxRetryDelay || d > MaxRetryDelay*3/2 {
+			t.Errorf("backoff(1s, %d) = %s, want MaxRetryDelay plus up to 50%% jitter", attempt, d)
+		}
+	}
+}
+
+// flakyStreamingModel streams part of a response and fails with a 503
+// on the first query, then streams its full response.
+type flakyStreamingModel struct {
+	calls int
+}
+
+func (m *flakyStreamingModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	return m.QueryStream(ctx, messages, func(string) error { return nil })
+}
+
+func (m *flakyStreamingModel) QueryStream(ctx context.Context, messages []Message, onChunk func(chunk string) error) (string, TokenUsage, error) {
+	m.calls++
+	if m.calls == 1 {
+		onChunk("```bash\nTASK_")
+		return "", TokenUsage{}, &models.QueryError{StatusCode: http.StatusServiceUnavailable, Err: errors.New("unavailable")}
+	}
+	response := "```bash\nTASK_COMPLETE\n```"
+	onChunk(response)
+	return response, TokenUsage{}, nil
+}
+
+func TestStreamedRetryIsMarked(t *testing.T) {
+	var out bytes.Buffer
+	cfg := NewConfig().
+		WithStreaming(true).
+		WithOutput(&out).
+		WithRetry(2, time.Millisecond)
+	a, err := New(&flakyStreamingModel{}, echo.New(echo.NewConfig()), cfg)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if _, err := a.Run(context.Background(), "task"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	want := "```bash\nTASK_" + retryNotice + "```bash\nTASK_COMPLETE\n```"
+	if got := out.String(); !strings.HasPrefix(got, want) {
+		t.Errorf("streamed output = %q, want it to start with %q", got, want)
+	}
+}
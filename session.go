@@ -0,0 +1,93 @@
+package wise
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/j0lvera/wise/executor"
+	"github.com/j0lvera/wise/models"
+)
+
+// sessionVersion is bumped on incompatible changes to the session format.
+const sessionVersion = 1
+
+// session is the serialized form of a conversation.
+type session struct {
+	Version  int              `json:"version"`
+	Step     int              `json:"step"`
+	Usage    sessionUsage     `json:"usage"`
+	Messages []sessionMessage `json:"messages"`
+}
+
+type sessionMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type sessionUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// SaveSession writes the conversation history, step and usage as JSON.
+func (a *baseAgent) SaveSession(w io.Writer) error {
+	messages := a.history()
+	s := session{
+		Version: sessionVersion,
+		Step:    a.step,
+		Usage: sessionUsage{
+			PromptTokens:     a.totalUsage.PromptTokens,
+			CompletionTokens: a.totalUsage.CompletionTokens,
+			TotalTokens:      a.totalUsage.TotalTokens,
+		},
+		Messages: make([]sessionMessage, len(messages)),
+	}
+	for i, m := range messages {
+		s.Messages[i] = sessionMessage{Role: m.Role, Content: m.Content}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s); err != nil {
+		return fmt.Errorf("failed to save session: %w", err)
+	}
+	return nil
+}
+
+// Resume creates an agent from a session written by SaveSession.
+// The next Run continues the restored conversation instead of starting
+// over, and Continue can be used to grant more steps.
+func Resume(model models.Model, env executor.Environment, cfg Config, r io.Reader) (Agent, error) {
+	var s session
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("failed to load session: %w", err)
+	}
+	if s.Version != sessionVersion {
+		return nil, fmt.Errorf("unsupported session version %d", s.Version)
+	}
+
+	ag, err := New(model, env, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	a := ag.(*baseAgent)
+	a.step = s.Step
+	a.lastStep = s.Step
+	a.totalUsage = models.TokenUsage{
+		PromptTokens:     s.Usage.PromptTokens,
+		CompletionTokens: s.Usage.CompletionTokens,
+		TotalTokens:      s.Usage.TotalTokens,
+	}
+	a.messages = make([]Message, len(s.Messages))
+	a.transcript = make([]TranscriptEntry, len(s.Messages))
+	for i, m := range s.Messages {
+		a.messages[i] = Message{Role: m.Role, Content: m.Content}
+		a.transcript[i] = TranscriptEntry{Role: m.Role, Content: m.Content}
+	}
+	a.resumed = true
+
+	return a, nil
+}
@@ -0,0 +1,87 @@
+package wise
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/j0lvera/wise/executor/echo"
+)
+
+// meteredModel answers with its responses in order, charging two tokens
+// per query, and records the conversation it was last sent.
+type meteredModel struct {
+	mu        sync.Mutex
+	responses []string
+	last      []Message
+}
+
+func (m *meteredModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.responses) == 0 {
+		return "", TokenUsage{}, errors.New("no response left")
+	}
+	m.last = slices.Clone(messages)
+	response := m.responses[0]
+	m.responses = m.responses[1:]
+	return response, TokenUsage{PromptTokens: 1, CompletionTokens: 1, TotalTokens: 2}, nil
+}
+
+func TestResumeContinuesSavedSession(t *testing.T) {
+	env := echo.New(echo.NewConfig())
+	first, err := New(&meteredModel{responses: []string{"```bash\nls\n```"}}, env, NewConfig().WithMaxSteps(1))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	_, err = first.Run(context.Background(), "list the files")
+	var te *TerminatingErr
+	if !errors.As(err, &te) || te.Reason != ReasonStepLimit {
+		t.Fatalf("Run() error = %v, want the step limit", err)
+	}
+
+	var saved bytes.Buffer
+	if err := first.SaveSession(&saved); err != nil {
+		t.Fatalf("SaveSession() error = %v", err)
+	}
+
+	model := &meteredModel{responses: []string{"```bash\nTASK_COMPLETE\n```"}}
+	resumed, err := Resume(model, env, NewConfig().WithMaxSteps(2), &saved)
+	if err != nil {
+		t.Fatalf("Resume() error = %v", err)
+	}
+	if !slices.Equal(resumed.Messages(), first.Messages()) {
+		t.Fatalf("restored messages = %v, want %v", resumed.Messages(), first.Messages())
+	}
+	if resumed.Usage() != first.Usage() {
+		t.Errorf("restored usage = %+v, want %+v", resumed.Usage(), first.Usage())
+	}
+
+	if _, err := resumed.Run(context.Background(), "also check hidden files"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// The model sees the restored conversation plus the follow-up, not a
+	// fresh system prompt and task
+	want := append(first.Messages(), Message{Role: RoleUser, Content: "also check hidden files"})
+	if !slices.Equal(model.last, want) {
+		t.Errorf("resumed query = %v, want %v", model.last, want)
+	}
+	if got := resumed.LastStep(); got != 2 {
+		t.Errorf("LastStep() = %d, want 2 counting the saved step", got)
+	}
+	if got := resumed.Usage().TotalTokens; got != 4 {
+		t.Errorf("Usage().TotalTokens = %d, want 4 across both runs", got)
+	}
+}
+
+func TestResumeRejectsUnknownVersion(t *testing.T) {
+	_, err := Resume(&scriptedModel{}, echo.New(echo.NewConfig()), NewConfig(), strings.NewReader(`{"version": 99}`))
+	if err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("Resume() error = %v, want the unsupported version", err)
+	}
+}
@@ -0,0 +1,65 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// GracefulShutdownTimeout bounds the summary step of a graceful shutdown.
+const GracefulShutdownTimeout = time.Minute
+
+// shutdownPrompt asks the model to wrap up an interrupted run.
+const shutdownPrompt = "The run was interrupted by the user. Do not continue the task. " +
+	"Reply with a single command that prints %s followed by a summary of the progress so far, " +
+	"including what remains to be done."
+
+// forceStopKey is the context key of the force-stop context.
+type forceStopKey struct{}
+
+// WithForceStop returns a copy of ctx carrying force. With graceful
+// shutdown, cancelling ctx starts the summary step, which ignores ctx;
+// cancelling force, e.g. on a second interrupt, aborts that step and
+// ends the run at once.
+func WithForceStop(ctx, force context.Context) context.Context {
+	return context.WithValue(ctx, forceStopKey{}, force)
+}
+
+// shutdown gives the model one bounded step to summarize an interrupted
+// run and returns the summary as a user abort caused by cause.
+func (a *baseAgent) shutdown(ctx context.Context, cause error) (string, error) {
+	force, _ := ctx.Value(forceStopKey{}).(context.Context)
+	if force != nil && force.Err() != nil {
+		a.cfg.logger.Warn().Msg("run force-stopped, skipping the summary")
+		output := a.lastAssistantMessage()
+		a.notifyTerminate(ReasonUserAbort)
+		return output, &TerminatingErr{Reason: ReasonUserAbort, Output: output, Err: cause}
+	}
+
+	a.cfg.logger.Warn().Msg("run cancelled, asking for a summary")
+
+	stepCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), GracefulShutdownTimeout)
+	defer cancel()
+	if force != nil {
+		stop := context.AfterFunc(force, cancel)
+		defer stop()
+	}
+
+	a.addMessage(RoleUser, fmt.Sprintf(shutdownPrompt, a.cfg.completionMarker))
+	a.lastStep = a.step + 1
+	a.notifyStepStart()
+
+	_, err := a.Step(stepCtx)
+
+	output := a.lastAssistantMessage()
+	var termErr *TerminatingErr
+	if errors.As(err, &termErr) {
+		output = termErr.Output
+	} else if err != nil {
+		a.cfg.logger.Warn().Err(err).Msg("summary step failed")
+	}
+
+	a.notifyTerminate(ReasonUserAbort)
+	return output, &TerminatingErr{Reason: ReasonUserAbort, Output: output, Err: cause}
+}
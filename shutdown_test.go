@@ -0,0 +1,73 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/j0lvera/wise/executor/echo"
+)
+
+// blockingModel waits for the query to be cancelled.
+type blockingModel struct {
+	queries atomic.Int32
+}
+
+func (m *blockingModel) Query(ctx context.Context, messages []Message) (string, TokenUsage, error) {
+	m.queries.Add(1)
+	<-ctx.Done()
+	return "", TokenUsage{}, ctx.Err()
+}
+
+func TestGracefulShutdownForceStopAbortsSummary(t *testing.T) {
+	model := &blockingModel{}
+	a, err := New(model, echo.New(echo.NewConfig()), NewConfig().WithGracefulShutdown(true))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	force, forceStop := context.WithCancel(context.Background())
+	defer cancel()
+	defer forceStop()
+	time.AfterFunc(50*time.Millisecond, cancel)
+	time.AfterFunc(150*time.Millisecond, forceStop)
+
+	start := time.Now()
+	_, err = a.Run(WithForceStop(ctx, force), "task")
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("Run() returned after %s, want the summary aborted", elapsed)
+	}
+
+	var termErr *TerminatingErr
+	if !errors.As(err, &termErr) || termErr.Reason != ReasonUserAbort {
+		t.Fatalf("Run() error = %v, want a user abort", err)
+	}
+	if got := model.queries.Load(); got != 2 {
+		t.Errorf("model queried %d times, want the step and the summary", got)
+	}
+}
+
+func TestGracefulShutdownSkipsSummaryWhenAlreadyForced(t *testing.T) {
+	model := &blockingModel{}
+	a, err := New(model, echo.New(echo.NewConfig()), NewConfig().WithGracefulShutdown(true))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	force, forceStop := context.WithCancel(context.Background())
+	cancel()
+	forceStop()
+
+	_, err = a.Run(WithForceStop(ctx, force), "task")
+	var termErr *TerminatingErr
+	if !errors.As(err, &termErr) || termErr.Reason != ReasonUserAbort {
+		t.Fatalf("Run() error = %v, want a user abort", err)
+	}
+	if got := model.queries.Load(); got != 0 {
+		t.Errorf("model queried %d times, want no summary step", got)
+	}
+}
@@ -0,0 +1,49 @@
+package wise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// Span attribute keys.
+const (
+	attrStep             = attribute.Key("wise.step")
+	attrMaxSteps         = attribute.Key("wise.max_steps")
+	attrCommandHash      = attribute.Key("wise.command.sha256")
+	attrExitCode         = attribute.Key("wise.command.exit_code")
+	attrTimedOut         = attribute.Key("wise.command.timed_out")
+	attrPromptTokens     = attribute.Key("wise.tokens.prompt")
+	attrCompletionTokens = attribute.Key("wise.tokens.completion")
+)
+
+// noopTracer is used when no tracer is configured.
+var noopTracer = noop.NewTracerProvider().Tracer("")
+
+// startSpan starts a child span of the one carried by ctx.
+func (a *baseAgent) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return a.cfg.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan records err on the span and ends it. Terminations are the
+// normal end of a run, not failures.
+func endSpan(span trace.Span, err error) {
+	var termErr *TerminatingErr
+	if err != nil && !errors.As(err, &termErr) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// commandHash identifies a command in spans without recording its text.
+func commandHash(command string) string {
+	sum := sha256.Sum256([]byte(command))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,144 @@
+package wise
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"github.com/j0lvera/wise/executor/echo"
+)
+
+// recordedSpan keeps what the agent sets on a span.
+type recordedSpan struct {
+	noop.Span
+	name   string
+	parent *recordedSpan
+	attrs  map[attribute.Key]attribute.Value
+	status codes.Code
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) {
+	for _, a := range kv {
+		s.attrs[a.Key] = a.Value
+	}
+}
+
+func (s *recordedSpan) SetStatus(code codes.Code, _ string) { s.status = code }
+func (s *recordedSpan) End(...trace.SpanEndOption)          { s.ended = true }
+
+// recordingTracer records every span it starts.
+type recordingTracer struct {
+	noop.Tracer
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	s := &recordedSpan{name: name, attrs: map[attribute.Key]attribute.Value{}}
+	if parent, ok := trace.SpanFromContext(ctx).(*recordedSpan); ok {
+		s.parent = parent
+	}
+	cfg := trace.NewSpanStartConfig(opts...)
+	s.SetAttributes(cfg.Attributes()...)
+
+	t.mu.Lock()
+	t.spans = append(t.spans, s)
+	t.mu.Unlock()
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+func (t *recordingTracer) named(name string) []*recordedSpan {
+	var spans []*recordedSpan
+	for _, s := range t.spans {
+		if s.name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func TestTracingSpansFollowTheLoop(t *testing.T) {
+	tracer := &recordingTracer{}
+	model := &scriptedModel{responses: []string{
+		"```bash\nls\n```",
+		"```bash\nTASK_COMPLETE\n```",
+	}}
+	a, err := New(model, echo.New(echo.NewConfig()), NewConfig().WithTracer(tracer))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := a.Run(context.Background(), "list the files"); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	for _, s := range tracer.spans {
+		if !s.ended {
+			t.Errorf("span %s was not ended", s.name)
+		}
+		if s.status == codes.Error {
+			t.Errorf("span %s has an error status for a successful run", s.name)
+		}
+	}
+
+	runs := tracer.named("wise.run")
+	if len(runs) != 1 || runs[0].parent != nil {
+		t.Fatalf("wise.run spans = %d, want one root", len(runs))
+	}
+
+	steps := tracer.named("wise.step")
+	if len(steps) != 2 {
+		t.Fatalf("wise.step spans = %d, want 2", len(steps))
+	}
+	for i, s := range steps {
+		if s.parent != runs[0] {
+			t.Errorf("step %d isn't a child of the run", i+1)
+		}
+		if got := s.attrs[attrStep].AsInt64(); got != int64(i+1) {
+			t.Errorf("step span %d has %s = %d", i+1, attrStep, got)
+		}
+	}
+
+	queries := tracer.named("wise.model.query")
+	commands := tracer.named("wise.command")
+	if len(queries) != 2 || len(commands) != 2 {
+		t.Fatalf("got %d query and %d command spans, want 2 of each", len(queries), len(commands))
+	}
+	for i := range steps {
+		if queries[i].parent != steps[i] || commands[i].parent != steps[i] {
+			t.Errorf("step %d's query and command spans aren't its children", i+1)
+		}
+	}
+
+	// Commands are identified by hash, not by their text
+	if got := commands[0].attrs[attrCommandHash].AsString(); got != commandHash("ls") {
+		t.Errorf("command span hash = %q, want the hash of ls", got)
+	}
+	if _, ok := commands[0].attrs[attrExitCode]; !ok {
+		t.Error("command span has no exit code")
+	}
+}
+
+func TestTracingRecordsQueryFailure(t *testing.T) {
+	tracer := &recordingTracer{}
+	a, err := New(failingModel{err: errors.New("invalid api key")}, echo.New(echo.NewConfig()), NewConfig().WithTracer(tracer))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if _, err := a.Run(context.Background(), "list the files"); err == nil {
+		t.Fatal("Run() error = nil, want the model's error")
+	}
+
+	for _, name := range []string{"wise.model.query", "wise.step"} {
+		spans := tracer.named(name)
+		if len(spans) == 0 || spans[len(spans)-1].status != codes.Error {
+			t.Errorf("%s span doesn't record the failure", name)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+package wise
+
+import "slices"
+
+// TranscriptEntry is a message tagged with the step that produced it.
+type TranscriptEntry struct {
+	Step    int // 0 for messages added before the first step
+	Role    string
+	Content string
+}
+
+// Transcript returns every message of the current run in order, tagged
+// with its step. Unlike Messages, it keeps messages a MemoryStrategy has
+// trimmed. Follow-up messages from Chat carry the step before them, and
+// messages restored by Resume have step 0.
+func (a *baseAgent) Transcript() []TranscriptEntry {
+	a.convMu.Lock()
+	defer a.convMu.Unlock()
+	return slices.Clone(a.transcript)
+}
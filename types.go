@@ -2,6 +2,7 @@ package wise
 
 import (
 	"context"
+	"io"
 
 	"github.com/j0lvera/wise/executor"
 	"github.com/j0lvera/wise/models"
@@ -26,6 +27,19 @@ const (
 type Agent interface {
 	Run(ctx context.Context, task string) (string, error)
 	Step(ctx context.Context) (string, error)
+	Continue(ctx context.Context, additionalSteps int) (string, error)
+	Chat(ctx context.Context, message string) (string, error)
+	Plan(ctx context.Context, task string) (string, error)
+	Messages() []Message
+	Transcript() []TranscriptEntry
+	Result() Result
+	LastStep() int
+	Close() error
+	Usage() TokenUsage
+	Cost() float64
+	SaveSession(w io.Writer) error
+	ExportMessages(w io.Writer) error
+	Events() <-chan Event
 }
 
 // Parser extracts actions from LLM responses.
@@ -33,6 +47,49 @@ type Parser interface {
 	ParseAction(response string) (Action, error)
 }
 
+// MultiParser extracts an ordered list of actions from LLM responses.
+type MultiParser interface {
+	ParseActions(response string) ([]Action, error)
+}
+
+// ThoughtSplitter is implemented by parsers that can separate a
+// response's reasoning from its commands. The agent stores only message
+// in the history and reports thought through Hooks.OnThought.
+type ThoughtSplitter interface {
+	SplitThought(response string) (thought, message string)
+}
+
+// TerminationCheck decides whether a command's output completes the
+// task, given the conversation so far. Returning done ends the run with
+// ReasonComplete and final as its output.
+type TerminationCheck func(output Output, messages []Message) (done bool, final string)
+
 // ActionHandler processes custom action types.
 // Returns (output, handled, error) - if handled is false, default processing is used.
 type ActionHandler func(ctx context.Context, action Action) (Output, bool, error)
+
+// Conversation is the view of the conversation given to a
+// ContextActionHandler.
+type Conversation interface {
+	// Messages returns a copy of the conversation so far.
+	Messages() []Message
+	// AddMessage appends a message, placed before the action's observation.
+	AddMessage(role, content string)
+}
+
+// DescribedActionHandler is an action handler for a tool that describes
+// itself, so the agent can list it in the system prompt. HandleAction
+// behaves like an ActionHandler.
+type DescribedActionHandler interface {
+	Name() string
+	Description() string
+	HandleAction(ctx context.Context, action Action) (Output, bool, error)
+}
+
+// ContextActionHandler is an ActionHandler that can read and extend the
+// conversation, e.g. to give the model context about a custom tool.
+type ContextActionHandler func(ctx context.Context, conv Conversation, action Action) (Output, bool, error)
+
+// ApprovalFunc decides whether an action may run. Returning false rejects
+// the action; returning an error aborts the run.
+type ApprovalFunc func(ctx context.Context, action Action) (bool, error)
@@ -0,0 +1,29 @@
+package wise
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// wait handles a wait action by pausing for its duration, without
+// running a command. Cancellation ends the wait early.
+func wait(ctx context.Context, action Action) (Output, error) {
+	d, err := time.ParseDuration(action.Command)
+	if err != nil || d <= 0 {
+		return Output{}, &ProcessErr{
+			Type:    ProcessErrFormat,
+			Message: fmt.Sprintf("Invalid wait duration %q. Use a positive duration such as ```wait 30s```.", action.Command),
+		}
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return Output{}, ctx.Err()
+	case <-timer.C:
+		return Output{Stdout: fmt.Sprintf("(waited %s)", d), Duration: d}, nil
+	}
+}